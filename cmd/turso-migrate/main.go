@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/rubenmeza/turso-migrate/internal/cli"
 )
@@ -10,7 +13,13 @@ import (
 func main() {
 	app := cli.NewApp()
 
-	if err := app.Run(os.Args); err != nil {
+	// Cancel the running command on Ctrl-C or SIGTERM, so a hung migration
+	// is interrupted and rolled back instead of leaving the process stuck
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	err := app.RunContext(ctx, os.Args)
+	stop()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,41 @@
+-- Migration: Add audit log for post changes
+-- Created: 2024-01-08 09:45:00
+
+-- ==== UP ====
+CREATE TABLE post_audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id TEXT NOT NULL,
+    action TEXT NOT NULL,
+    detail TEXT,
+    logged_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Record publishing and unpublishing; the CASE ... END inside the trigger
+-- body does not end the trigger
+CREATE TRIGGER posts_audit_update
+    AFTER UPDATE OF published ON posts
+    FOR EACH ROW
+    WHEN OLD.published <> NEW.published
+BEGIN
+    INSERT INTO post_audit_log (post_id, action, detail)
+    VALUES (
+        NEW.id,
+        CASE WHEN NEW.published THEN 'publish' ELSE 'unpublish' END,
+        'title: ' || NEW.title
+    );
+END;
+
+-- Semicolons inside string literals are not statement separators either
+CREATE TRIGGER posts_audit_delete
+    BEFORE DELETE ON posts
+    FOR EACH ROW
+BEGIN
+    SELECT RAISE(ABORT, 'published posts cannot be deleted; unpublish first')
+    WHERE OLD.published;
+    INSERT INTO post_audit_log (post_id, action) VALUES (OLD.id, 'delete');
+END;
+
+-- ==== DOWN ====
+DROP TRIGGER posts_audit_delete;
+DROP TRIGGER posts_audit_update;
+DROP TABLE post_audit_log;
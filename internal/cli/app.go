@@ -1,8 +1,18 @@
 package cli
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/rubenmeza/turso-migrate/internal/migration"
 	"github.com/rubenmeza/turso-migrate/internal/storage"
@@ -14,7 +24,7 @@ const version = "1.0.0"
 
 // NewApp creates a new CLI application
 func NewApp() *cli.App {
-	return &cli.App{
+	app := &cli.App{
 		Name:    "turso-migrate",
 		Usage:   "A simple database migration tool for Turso (libSQL)",
 		Version: version,
@@ -27,6 +37,17 @@ func NewApp() *cli.App {
 Built specifically for libSQL with first-class Docker support and 
 straightforward integration for modern applications.`,
 		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "config",
+				Usage:   "Config file with default settings (default: " + config.DefaultFile + " if present)",
+				EnvVars: []string{"MIGRATE_CONFIG"},
+			},
+			&cli.StringFlag{
+				Name:    "env",
+				Aliases: []string{"profile"},
+				Usage:   "Use the settings of this profile of the config file, such as dev or prod",
+				EnvVars: []string{"MIGRATE_ENV"},
+			},
 			&cli.StringFlag{
 				Name:    "database-url",
 				Aliases: []string{"d"},
@@ -39,6 +60,11 @@ straightforward integration for modern applications.`,
 				Usage:   "Turso auth token (overrides TURSO_AUTH_TOKEN)",
 				EnvVars: []string{"TURSO_AUTH_TOKEN"},
 			},
+			&cli.StringFlag{
+				Name:    "auth-token-file",
+				Usage:   "Read the Turso auth token from this file, such as a mounted secret, instead of --auth-token",
+				EnvVars: []string{"TURSO_AUTH_TOKEN_FILE"},
+			},
 			&cli.StringFlag{
 				Name:    "migrations-dir",
 				Aliases: []string{"m"},
@@ -46,6 +72,143 @@ straightforward integration for modern applications.`,
 				Value:   "./migrations",
 				EnvVars: []string{"MIGRATIONS_DIR"},
 			},
+			&cli.StringFlag{
+				Name:    "max-migration-size",
+				Usage:   "Reject migration files larger than this size (e.g. 512KB, 16MB; 0 disables)",
+				Value:   "16MB",
+				EnvVars: []string{"MAX_MIGRATION_SIZE"},
+			},
+			&cli.StringFlag{
+				Name:    "filename-pattern",
+				Usage:   "Regular expression for migration filenames with (?P<version>...) and (?P<name>...) groups",
+				Value:   migration.DefaultFilenamePattern,
+				EnvVars: []string{"FILENAME_PATTERN"},
+			},
+			&cli.StringFlag{
+				Name:    "compat",
+				Usage:   "Read migrations laid out for another tool (flyway, goose)",
+				EnvVars: []string{"MIGRATE_COMPAT"},
+			},
+			&cli.StringFlag{
+				Name:    "namespace",
+				Usage:   "Manage an independent migration history: migrations in <migrations-dir>/<namespace>, tracked in schema_migrations_<namespace>",
+				EnvVars: []string{"MIGRATE_NAMESPACE"},
+			},
+			&cli.StringFlag{
+				Name:    "version-format",
+				Usage:   "How create numbers new migrations (sequential, timestamp)",
+				Value:   migration.VersionSequential,
+				EnvVars: []string{"MIGRATE_VERSION_FORMAT"},
+			},
+			&cli.StringFlag{
+				Name:    "dialect",
+				Usage:   "SQL dialect used for the migrations table (sqlite)",
+				Value:   "sqlite",
+				EnvVars: []string{"MIGRATE_DIALECT"},
+			},
+			&cli.StringFlag{
+				Name:    "tracking-db",
+				Usage:   "Attach this database and keep the migrations table in it instead of the main database",
+				EnvVars: []string{"TRACKING_DB"},
+			},
+			&cli.StringFlag{
+				Name:    "lock-table",
+				Usage:   "Name of the migration lock table (default: the migrations table name followed by _lock)",
+				EnvVars: []string{"LOCK_TABLE"},
+			},
+			&cli.DurationFlag{
+				Name:    "lock-timeout",
+				Usage:   "How long up and down wait for another run to release the migration lock",
+				Value:   30 * time.Second,
+				EnvVars: []string{"LOCK_TIMEOUT"},
+			},
+			&cli.DurationFlag{
+				Name:    "lock-retry-interval",
+				Usage:   "Delay between attempts to take the migration lock",
+				Value:   time.Second,
+				EnvVars: []string{"LOCK_RETRY_INTERVAL"},
+			},
+			&cli.DurationFlag{
+				Name:    "lock-stale-after",
+				Usage:   "Age after which a migration lock is assumed to be left by a crashed run and removed (0, the default, never removes it)",
+				EnvVars: []string{"LOCK_STALE_AFTER"},
+			},
+			&cli.IntFlag{
+				Name:    "exec-retries",
+				Aliases: []string{"max-retries"},
+				Usage:   "Retry connecting and migration SQL this many times on transient network errors",
+				EnvVars: []string{"EXEC_RETRIES"},
+			},
+			&cli.DurationFlag{
+				Name:    "exec-retry-delay",
+				Aliases: []string{"retry-delay"},
+				Usage:   "Delay before the first retry, doubling after each attempt",
+				Value:   time.Second,
+				EnvVars: []string{"EXEC_RETRY_DELAY"},
+			},
+			&cli.DurationFlag{
+				Name:    "timeout",
+				Usage:   "Fail a migration whose UP or DOWN SQL runs longer than this (0 means no limit)",
+				EnvVars: []string{"MIGRATE_TIMEOUT"},
+			},
+			&cli.StringFlag{
+				Name:    "color",
+				Usage:   "Colorize output: always, auto (only on a terminal) or never; NO_COLOR forces never",
+				Value:   migration.ColorAuto,
+				EnvVars: []string{"MIGRATE_COLOR"},
+			},
+			&cli.BoolFlag{
+				Name:  "no-color",
+				Usage: "Same as --color never",
+			},
+			&cli.BoolFlag{
+				Name:    "quiet",
+				Usage:   "Suppress normal output; errors are still printed",
+				EnvVars: []string{"MIGRATE_QUIET"},
+			},
+			&cli.BoolFlag{
+				Name:    "verbose",
+				Usage:   "Print each SQL statement of a migration before executing it",
+				EnvVars: []string{"MIGRATE_VERBOSE"},
+			},
+			&cli.BoolFlag{
+				Name:    "skip-invalid",
+				Usage:   "Skip migration files that fail to parse, with a warning, instead of failing",
+				EnvVars: []string{"MIGRATE_SKIP_INVALID"},
+			},
+			&cli.BoolFlag{
+				Name:    "expand-env",
+				Usage:   "Replace ${NAME} in migration SQL with environment variables; unset variables are errors",
+				EnvVars: []string{"MIGRATE_EXPAND_ENV"},
+			},
+			&cli.BoolFlag{
+				Name:    "read-only",
+				Usage:   "Refuse every command that writes, and never create or alter the migrations table",
+				EnvVars: []string{"MIGRATE_READ_ONLY"},
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Show what create, up, down or snapshot would do without changing anything",
+			},
+			&cli.StringFlag{
+				Name:    "confirm-phrase",
+				Usage:   "Phrase to type before down or goto runs; {database} stands for the database name",
+				EnvVars: []string{"MIGRATE_CONFIRM_PHRASE"},
+			},
+			&cli.StringFlag{
+				Name:    "min-version",
+				Usage:   "Refuse to run if this binary is older than the given version",
+				EnvVars: []string{"MIGRATE_MIN_VERSION"},
+			},
+			&cli.BoolFlag{
+				Name:    "yes",
+				Aliases: []string{"non-interactive"},
+				Usage:   "Never ask for confirmation; also assumed when CI=true",
+			},
+			&cli.BoolFlag{
+				Name:  "error-json",
+				Usage: "Print errors to stderr as JSON for tooling",
+			},
 		},
 		Commands: []*cli.Command{
 			{
@@ -53,55 +216,828 @@ straightforward integration for modern applications.`,
 				Aliases:   []string{"c"},
 				Usage:     "Create a new migration file for your Turso database",
 				ArgsUsage: "<name>",
+				Before:    validateLocalConfig,
 				Action:    createCommand,
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:  "version-width",
+						Usage: "Zero-padding width of the generated version",
+						Value: migration.DefaultVersionWidth,
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Print the path and content of the migration without creating it",
+					},
+					&cli.BoolFlag{
+						Name:  "no-down",
+						Usage: "Omit the DOWN section and mark the migration irreversible",
+					},
+					&cli.BoolFlag{
+						Name:  "sql-only",
+						Usage: "Omit the UP and DOWN markers; the whole file is the UP section",
+					},
+					&cli.StringFlag{
+						Name:  "template",
+						Usage: "Render the header comment block from this text/template file, or generate the SQL from a built-in template: create-table:<table>, add-column:<table>.<column> or create-index:<table>.<column>",
+					},
+					&cli.BoolFlag{
+						Name:  "empty",
+						Usage: "Leave out the header comment block; write only the UP and DOWN markers",
+					},
+					&cli.StringFlag{
+						Name:  "note",
+						Usage: "Write this note as a \"-- Note:\" header; it is recorded when the migration is applied and shown by status",
+					},
+					&cli.StringFlag{
+						Name:  "dir",
+						Usage: "Create the file in this subdirectory of the migrations directory, e.g. 2024-q3",
+					},
+				},
 				Description: `Create a new migration file with the given name.
 The file will be created with auto-incremented version number and
 pre-filled UP and DOWN sections optimized for Turso/libSQL.
 
+--no-down leaves out the DOWN section and adds a "-- migrate:irreversible"
+line, for backfills and other changes with no meaningful rollback; down
+then reports the migration as irreversible. --sql-only leaves out both
+markers.
+
+--dir puts the file in a subdirectory, to group migrations by quarter or
+feature. Migrations are read from all subdirectories and versions stay
+unique and ordered across them.
+
+--template replaces the default header with a Go text/template file,
+which can use {{.Name}} and {{.Created}}. The section markers are still
+added by create, so the template must not contain them.
+
 Example:
-  turso-migrate create add_users_table`,
+  turso-migrate create add_users_table
+  turso-migrate create --no-down backfill_user_emails`,
 			},
 			{
 				Name:    "up",
 				Aliases: []string{"u"},
 				Usage:   "Apply all pending migrations to your Turso database",
 				Action:  upCommand,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "approve",
+						Usage: "Approve the manual migration with this version and continue",
+					},
+					&cli.StringFlag{
+						Name:  "shard",
+						Usage: "Only apply migrations whose version hashes into this shard, e.g. 1/4",
+					},
+					&cli.IntFlag{
+						Name:  "concurrency",
+						Usage: "Apply migrations marked migrate:independent in parallel using this many connections; local databases use one connection, so they still run serially",
+						Value: 1,
+					},
+					&cli.StringFlag{
+						Name:  "assert-schema",
+						Usage: "After applying, fail if the live schema differs from the statements in this file",
+					},
+					&cli.StringFlag{
+						Name:  "after-date",
+						Usage: "Only apply migrations created after this date (YYYY-MM-DD or RFC 3339)",
+					},
+					&cli.StringFlag{
+						Name:  "git-ref",
+						Usage: "Apply the migration files as they were at this git commit",
+					},
+					&cli.DurationFlag{
+						Name:  "apply-timeout",
+						Usage: "Time budget for the whole run, e.g. 5m; stops before the next migration once exceeded without interrupting a running one",
+					},
+					&cli.BoolFlag{
+						Name:  "stream",
+						Usage: "Execute UP sections statement by statement from disk to reduce memory use",
+					},
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "Print a JSON summary of the run instead of the usual output",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Print the UP SQL that would run, in order, without executing it",
+					},
+					&cli.BoolFlag{
+						Name:  "strict",
+						Usage: "Refuse to run when a pending migration is older than the newest applied one",
+					},
+					&cli.BoolFlag{
+						Name:  "atomic",
+						Usage: "Apply all pending migrations in one transaction, keeping none if any fails",
+					},
+					&cli.IntFlag{
+						Name:  "limit",
+						Usage: "Apply at most this many pending migrations, in order (0 applies all)",
+					},
+					&cli.BoolFlag{
+						Name:  "check",
+						Usage: "Check the syntax of every pending statement with EXPLAIN before applying any",
+					},
+					&cli.StringFlag{
+						Name:  "only",
+						Usage: "Apply only the pending migration with this version",
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Let --only apply a migration out of order",
+					},
+					&cli.StringFlag{
+						Name:    "before-hook",
+						Usage:   "SQL to run before the first pending migration, outside any transaction; @path reads it from a file",
+						EnvVars: []string{"MIGRATE_BEFORE_HOOK"},
+					},
+					&cli.StringFlag{
+						Name:    "after-hook",
+						Usage:   "SQL to run after the last pending migration, outside any transaction; @path reads it from a file",
+						EnvVars: []string{"MIGRATE_AFTER_HOOK"},
+					},
+					&cli.BoolFlag{
+						Name:  "strict-checksums",
+						Usage: "Fail instead of warning when an applied migration was modified",
+					},
+				},
 				Description: `Apply all pending migrations in order to your Turso database.
 Only migrations that haven't been applied yet will be executed.
-Each migration runs in its own transaction for data safety.`,
+Each migration runs in its own transaction for data safety.
+
+Migrations containing a "-- migrate:manual" line pause the run until
+they are approved:
+  turso-migrate up --approve 004
+
+--shard splits pending migrations across parallel workers by hashing
+their version. This breaks sequential ordering between shards and is
+only safe for independent, idempotent data migrations.
+
+--concurrency runs consecutive migrations marked "-- migrate:independent"
+in parallel; all other migrations still run serially in order.
+
+--only applies a single pending migration, leaving the others pending,
+to repair a broken environment. Applying it ahead of older pending
+migrations, or after a newer applied one, also needs --force.
+
+--before-hook and --after-hook run SQL around the whole run, such as
+PRAGMA foreign_keys=OFF and ON. A failed before hook stops the run
+before any migration; the after hook also runs when a migration fails.
+
+--apply-timeout limits the total duration of the run. It is checked
+between migrations, so a migration that has started always finishes;
+the run then stops and reports how many migrations remain pending.
+
+The checksum of each migration's UP SQL is recorded when it is applied.
+If an applied migration's file changes afterwards, up and status warn;
+--strict-checksums makes up fail instead, before applying anything.
+
+A pending migration older than the newest applied one, typically merged
+from another branch, is applied with a warning; --strict makes up fail
+instead so the ordering can be reviewed.
+
+--atomic applies every pending migration and its record in a single
+transaction committed at the end, so a failure keeps none of them.
+"-- migrate:commit" lines have no effect in this mode, and it cannot be
+combined with --stream or --concurrency.
+
+--limit 2 applies only the next two pending migrations, for staged
+rollouts; run up again to continue. The summary reports how many remain.
+
+--dry-run prints the UP SQL of each migration that would be applied,
+in order, without executing it or changing the migrations table.
+
+--json prints a single JSON object when the run ends, listing each
+applied migration with its duration, the totals, and on failure the
+failed version and error.`,
 			},
 			{
 				Name:    "down",
 				Aliases: []string{"d"},
 				Usage:   "Rollback the last applied migration from your Turso database",
 				Action:  downCommand,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "from-file",
+						Usage: "Read the DOWN SQL from this file when the migration file is missing",
+					},
+					&cli.StringFlag{
+						Name:  "version",
+						Usage: "Expected version of the last applied migration",
+					},
+					&cli.BoolFlag{
+						Name:  "soft-down",
+						Usage: "Keep the tracking record and mark it as rolled back instead of deleting it",
+					},
+					&cli.IntFlag{
+						Name:  "steps",
+						Usage: "Number of most recently applied migrations to roll back, newest first",
+						Value: 1,
+					},
+					&cli.BoolFlag{
+						Name:  "last-run",
+						Usage: "Roll back every migration applied by the most recent up run",
+					},
+					&cli.StringFlag{
+						Name:  "to",
+						Usage: "Roll back every migration applied after this version, newest first",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Print the DOWN SQL that would run, in order, without executing it",
+					},
+					&cli.BoolFlag{
+						Name:  "skip-missing-down",
+						Usage: "Remove the records of migrations without a DOWN section instead of aborting (requires --yes)",
+					},
+					&cli.BoolFlag{
+						Name:  "yes",
+						Usage: "Roll back without asking for confirmation, and confirm risky rollback options",
+					},
+				},
 				Description: `Rollback the most recently applied migration from your Turso database.
 This will execute the DOWN section of the migration file.
-Use with caution in production environments.`,
+Use with caution in production environments.
+
+If the migration file was deleted, supply the rollback SQL yourself:
+  turso-migrate down --from-file rollback.sql --version 004
+
+--steps 3 rolls back the last three applied migrations, newest first,
+each on its own. If one has no DOWN section the rollback stops there,
+leaving the migrations before it rolled back.
+
+--last-run rolls back, newest first, every migration applied by the
+most recent up run.
+
+--to 003 rolls back, newest first, every migration applied after 003,
+which must be applied. Like --last-run, nothing runs if any of them has
+no DOWN section.
+
+--dry-run prints the DOWN SQL that would run, in order, without
+executing it or changing the migrations table. It fails if any
+migration in the plan has no DOWN section.
+
+Before rolling back, the migrations to roll back are listed and
+confirmation is asked; --yes or CI=true skips it. With --confirm-phrase
+set, that phrase must be typed instead of "y".
+
+--skip-missing-down --yes removes the records of migrations without a
+DOWN section, without running any SQL, so a multi-migration rollback
+can continue past them. Their schema changes stay in the database.`,
+			},
+			{
+				Name:      "goto",
+				Usage:     "Migrate up or down to an exact version",
+				ArgsUsage: "<version>",
+				Action:    gotoCommand,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "yes",
+						Usage: "Skip the --confirm-phrase prompt",
+					},
+				},
+				Description: `Bring your Turso database to exactly the given version.
+
+If the version is ahead of the current one, pending migrations are
+applied in order up to and including it. If it is behind, applied
+migrations newer than it are rolled back, newest first, until it is the
+current version. The version must have a migration file, and every
+migration rolled back must have a DOWN section.
+
+Example:
+  turso-migrate goto 003`,
 			},
 			{
 				Name:    "status",
 				Aliases: []string{"s"},
 				Usage:   "Show migration status for your Turso database",
 				Action:  statusCommand,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "preview-latest",
+						Usage: "Print the UP SQL of the latest pending migration",
+					},
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "Print a JSON array with the status of each migration",
+					},
+					&cli.BoolFlag{
+						Name:  "durations",
+						Usage: "Show how long each applied migration took to execute",
+					},
+					&cli.BoolFlag{
+						Name:  "summary",
+						Usage: `Print only a one-line summary such as "3 applied, 2 pending, current version 005"`,
+					},
+				},
 				Description: `Show the status of all migrations for your Turso database.
-Displays which migrations have been applied and which are pending.`,
+Displays which migrations have been applied and which are pending.
+
+Pending migrations older than the newest applied one are marked as out
+of order; they usually come from a branch merged after later migrations
+were applied.
+
+--durations shows how long each applied migration took to execute, as
+recorded when it was applied, to help find migrations that risk deploy
+timeouts.
+
+--summary prints only a single line counting applied and pending
+migrations, with the current version, for deploy logs.
+
+--json prints a JSON array instead, one object per migration file with
+its version, name, whether it is applied and when (RFC 3339, or null),
+whether it is out of order and, when recorded, its duration_ms.`,
 			},
 			{
 				Name:    "version",
 				Aliases: []string{"v"},
 				Usage:   "Show current schema version of your Turso database",
 				Action:  versionCommand,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "print-applied-versions",
+						Usage: "Print every applied version, one per line",
+					},
+					&cli.StringFlag{
+						Name:  "order",
+						Usage: "Order of --print-applied-versions (asc, desc)",
+						Value: "asc",
+					},
+				},
 				Description: `Show the current schema version of your Turso database.
-This is the version of the last applied migration.`,
+This is the version of the last applied migration.
+
+--print-applied-versions prints only the applied versions, one per line,
+for comparing environments in shell pipelines:
+  diff <(turso-migrate -d $STAGING version --print-applied-versions) \
+       <(turso-migrate -d $PROD version --print-applied-versions)`,
+			},
+			{
+				Name:      "snapshot",
+				Usage:     "Create a migration that inserts the current rows of selected tables",
+				ArgsUsage: "[name]",
+				Action:    snapshotCommand,
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:     "tables",
+						Usage:    "Comma-separated tables to capture",
+						Required: true,
+					},
+					&cli.IntFlag{
+						Name:  "max-rows",
+						Usage: "Fail if a table has more than this many rows",
+						Value: 1000,
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Print the path and content of the migration without creating it",
+					},
+				},
+				Description: `Create a migration whose UP section inserts the current rows of the
+given tables with INSERT OR REPLACE and whose DOWN section deletes
+them, so small reference tables can be version-controlled as seed data.
+The name defaults to snapshot_<tables>.
+
+Example:
+  turso-migrate snapshot --tables roles,settings seed_roles`,
+			},
+			{
+				Name:   "changelog",
+				Usage:  "Generate a Markdown changelog from migration history",
+				Action: changelogCommand,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "output",
+						Usage: "Write the changelog to this file instead of stdout",
+					},
+				},
+				Description: `Print a Markdown list of migrations grouped by the date they were
+applied, newest first. Pending migrations are listed under
+"Unreleased". A "-- Description: ..." line in a migration's header
+comment is included next to its name.
+
+Example:
+  turso-migrate changelog --output CHANGELOG.md`,
+			},
+			{
+				Name:   "whoami",
+				Usage:  "Show which database you are connected to",
+				Action: whoamiCommand,
+				Description: `Show identifying information about the connected database: the URL
+with the auth token redacted, the connection type, the SQLite version
+and the current schema version. Only read-only queries are run, so
+this works with read-only credentials.`,
+			},
+			{
+				Name:    "doctor",
+				Aliases: []string{"diagnose"},
+				Usage:   "Check the configuration and the connection to the database",
+				Action:  doctorCommand,
+				// The checks report a missing or invalid configuration
+				// themselves rather than failing before they run
+				Before: func(*cli.Context) error { return nil },
+				Description: `Run a checklist for setting up turso-migrate: the database URL
+format, the auth token, the migrations directory, the connection to
+the database and access to the migrations table. Each check prints
+pass or fail, with a suggested fix for failures. Checks that depend on
+a failed one are skipped. Only read-only queries are run.
+
+The exit status is non-zero if any check fails.`,
+			},
+			{
+				Name:      "baseline",
+				Usage:     "Record existing migrations as applied without running them",
+				ArgsUsage: "<version>",
+				Action:    baselineCommand,
+				Description: `Record every migration file up to and including the given version as
+applied, without running any SQL. Use this when adopting turso-migrate
+on a database whose schema already matches those migrations; later
+migrations are then applied by up as usual.
+
+Baselining is refused once any migration is recorded, so it can't
+create duplicate records.
+
+Example:
+  turso-migrate baseline 012`,
+			},
+			{
+				Name:   "reset",
+				Usage:  "Roll back every migration and drop the migrations table",
+				Action: resetCommand,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "hard",
+						Usage: "Only drop the migrations table, without rolling anything back",
+					},
+					&cli.BoolFlag{
+						Name:  "yes",
+						Usage: "Reset without asking for confirmation",
+					},
+				},
+				Description: `Remove all migration state, for tearing down test databases. Every
+applied migration is rolled back, newest first, then the migrations
+table is dropped; nothing runs if any migration has no DOWN section.
+
+--hard only drops the migrations table, leaving the schema in place.
+
+Before anything changes you must type the --confirm-phrase, or the
+database name if none is set. Only --yes skips this; CI=true does not.
+
+Example:
+  turso-migrate reset --hard`,
+			},
+			{
+				Name:   "repair",
+				Usage:  "Reconcile the migrations table with the migration files",
+				Action: repairCommand,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "prune",
+						Usage: "Delete the records of applied migrations that have no file",
+					},
+					&cli.BoolFlag{
+						Name:  "yes",
+						Usage: "Prune without asking for confirmation",
+					},
+				},
+				Description: `List applied migrations whose file was renamed or deleted, which down
+can't roll back, and unrecorded files older than the newest applied
+migration, often the new name of a renamed file.
+
+--prune deletes the records without a file. Their schema changes are
+left in place and no file is changed; rename a file back instead to keep
+its history. The records are listed and confirmation is asked first;
+--yes or CI=true skips it.
+
+Example:
+  turso-migrate repair --prune`,
+			},
+			{
+				Name:    "force-unlock",
+				Aliases: []string{"unlock"},
+				Usage:   "Remove a migration lock left behind by a crashed run",
+				Action:  forceUnlockCommand,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "yes",
+						Usage: "Remove the lock without asking for confirmation",
+					},
+				},
+				Description: `Print which process holds the migration lock and since when, then
+remove it after confirmation. With --confirm-phrase set, that phrase
+must be typed instead of "y".
+
+Only use this when the run holding the lock is known to have died:
+removing the lock of a run still in progress lets another run apply
+the same migrations at the same time.`,
+			},
+			{
+				Name:   "validate",
+				Usage:  "Check that all migration files are valid",
+				Before: validateLocalConfig,
+				Action: validateCommand,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "parse-only",
+						Usage: "Only check that files have valid names and parse",
+					},
+					&cli.BoolFlag{
+						Name:  "only-new",
+						Usage: "Only check files not yet committed to git",
+					},
+					&cli.BoolFlag{
+						Name:  "fail-fast",
+						Usage: "Stop at the first problem instead of reporting all of them",
+					},
+				},
+				Description: `Check every migration file without connecting to the database.
+Reports invalid filenames, duplicate versions, empty UP sections and
+misplaced or repeated UP/DOWN markers. No database URL or auth token is
+needed.
+Every problem is reported with a final count unless --fail-fast is set.
+
+With --only-new only untracked or modified files are checked, which
+keeps pre-commit hooks fast. Outside a git repository all files are
+checked.`,
+			},
+			{
+				Name:    "list-files",
+				Aliases: []string{"list"},
+				Usage:   "List migration files as the loader sees them",
+				Before:  validateLocalConfig,
+				Action:  listFilesCommand,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "Print a JSON array instead of a table",
+					},
+				},
+				Description: `List every migration file found in the migrations directory with its
+version, name, path, whether it has a DOWN section and its SHA-256
+checksum, after --filename-pattern and --compat are applied. Only the
+files are read; the database is never contacted.`,
+			},
+			{
+				Name:  "manifest",
+				Usage: "Generate or verify a manifest of migration file checksums",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "file",
+						Usage: "Manifest path (default: manifest.json in the migrations directory)",
+					},
+				},
+				Subcommands: []*cli.Command{
+					{
+						Name:   "generate",
+						Usage:  "Write the manifest for the current migration files",
+						Before: validateLocalConfig,
+						Action: manifestGenerateCommand,
+					},
+					{
+						Name:   "verify",
+						Usage:  "Fail if migration files were added, removed or changed since the manifest was generated",
+						Before: validateLocalConfig,
+						Action: manifestVerifyCommand,
+					},
+				},
+				Description: `Record every migration's version, name and SHA-256 checksum in a
+manifest that can be committed, and check it in CI without connecting
+to the database:
+  turso-migrate manifest generate
+  turso-migrate manifest verify`,
+			},
+			{
+				Name:   "tui",
+				Usage:  "Line-based prompt for viewing status and applying migrations",
+				Action: tuiCommand,
+				Description: `Open a line-based session listing all migrations with their
+applied/pending state. Type a command and press Enter: a migration's
+number to preview its SQL, "a" to apply all pending migrations after
+confirmation, "r" to refresh and "q" to quit. The session is written
+to stderr, so --quiet does not hide it.`,
 			},
 		},
-		Before: func(c *cli.Context) error {
-			// Validate that we have required Turso configuration
-			cfg := buildConfig(c)
-			return cfg.Validate()
+		ExitErrHandler: func(c *cli.Context, err error) {
+			if err == nil || !c.Bool("error-json") {
+				return
+			}
+			writeErrorJSON(os.Stderr, err)
+			os.Exit(1)
 		},
 	}
+
+	addConnectionFlags(app.Commands)
+	return app
+}
+
+// addConnectionFlags repeats the connection flags on every command so they
+// can also be given after the command name, validates the configuration
+// once a command's own flags are parsed, and masks the auth token in the
+// errors the command returns
+func addConnectionFlags(commands []*cli.Command) {
+	for _, cmd := range commands {
+		if len(cmd.Subcommands) > 0 {
+			addConnectionFlags(cmd.Subcommands)
+			continue
+		}
+
+		cmd.Flags = append(cmd.Flags,
+			&cli.StringFlag{
+				Name:    "database-url",
+				Aliases: []string{"d"},
+				Usage:   "Turso database URL (overrides the global flag)",
+			},
+			&cli.StringFlag{
+				Name:    "auth-token",
+				Aliases: []string{"t"},
+				Usage:   "Turso auth token (overrides the global flag)",
+			},
+			&cli.StringFlag{
+				Name:  "auth-token-file",
+				Usage: "Read the Turso auth token from this file (overrides the global flag)",
+			},
+			&cli.StringFlag{
+				Name:    "migrations-dir",
+				Aliases: []string{"m"},
+				Usage:   "Directory containing migration files (overrides the global flag)",
+			},
+		)
+		if cmd.Before == nil {
+			cmd.Before = validateConfig
+		}
+		if cmd.Action != nil {
+			cmd.Action = redactErrors(cmd.Action)
+		}
+	}
+}
+
+// redactErrors wraps action so that no error it returns, from the engine,
+// the storage or the database driver, shows the auth token
+func redactErrors(action cli.ActionFunc) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		err := action(c)
+		if err == nil {
+			return nil
+		}
+		var token string
+		if cfg, cfgErr := buildConfig(c); cfgErr == nil {
+			token = cfg.AuthToken
+		}
+		return storage.RedactError(err, token)
+	}
+}
+
+// namespacePattern matches valid --namespace values, which become part of
+// the tracking table name
+var namespacePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// validateConfig checks that we have the required Turso configuration
+func validateConfig(c *cli.Context) error {
+	cfg, err := buildConfig(c)
+	if err != nil {
+		return err
+	}
+	if err := cfg.CheckMinVersion(version); err != nil {
+		return err
+	}
+	return cfg.Validate()
+}
+
+// validateLocalConfig checks the configuration of commands that only read
+// or write migration files, which don't need database credentials
+func validateLocalConfig(c *cli.Context) error {
+	cfg, err := buildConfig(c)
+	if err != nil {
+		return err
+	}
+	return cfg.CheckMinVersion(version)
+}
+
+// applyConfigFile sets the settings of the config file on cfg, except
+// those whose flag was given on the command line or through its
+// environment variable, so flags take precedence over the environment and
+// both over the file
+func applyConfigFile(c *cli.Context, cfg *config.Config) error {
+	file, err := config.OpenFile(lineageString(c, "config"))
+	if err != nil {
+		return err
+	}
+
+	profile := lineageString(c, "env")
+	if file == nil {
+		if profile != "" {
+			return fmt.Errorf("--env %s needs a config file with profiles, but %s was not found", profile, config.DefaultFile)
+		}
+		return nil
+	}
+	if profile != "" {
+		if err := file.UseProfile(profile); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range file.Keys() {
+		if flagSet(c, strings.ReplaceAll(key, "_", "-")) || flagSet(c, replacedBy[key]) {
+			continue
+		}
+		if err := cfg.Set(key, file.Settings[key]); err != nil {
+			return fmt.Errorf("%s: %w", file.Path, err)
+		}
+	}
+	return nil
+}
+
+// replacedBy maps config file keys to the flag that replaces them when
+// given, on top of the key's own flag: a token file given on the command
+// line overrides a token from the file, and the other way around
+var replacedBy = map[string]string{
+	"auth_token":      "auth-token-file",
+	"auth_token_file": "auth-token",
+}
+
+// hookSQL returns the SQL of an up hook, read from a file when value is
+// "@" followed by its path
+func hookSQL(value string) (string, error) {
+	path, ok := strings.CutPrefix(value, "@")
+	if !ok {
+		return value, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read hook: %w", err)
+	}
+	return string(content), nil
+}
+
+// flagSet reports whether the flag name was set in any command of the
+// lineage, on the command line or through its environment variable
+func flagSet(c *cli.Context, name string) bool {
+	for _, ctx := range c.Lineage() {
+		if ctx.IsSet(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// lineageString returns the value of a string flag from the innermost
+// command that set it, falling back to the global flag's value
+func lineageString(c *cli.Context, name string) string {
+	lineage := c.Lineage()
+	for _, ctx := range lineage {
+		if ctx.IsSet(name) {
+			return ctx.String(name)
+		}
+	}
+	return lineage[len(lineage)-1].String(name)
+}
+
+// yesFlag reports whether --yes was given, either before or after the
+// command name
+func yesFlag(c *cli.Context) bool {
+	for _, ctx := range c.Lineage() {
+		if ctx.Bool("yes") {
+			return true
+		}
+	}
+	return false
+}
+
+// assumeYes reports whether confirmations are skipped: with --yes, or
+// when the CI environment variable is true as CI services set it
+func assumeYes(c *cli.Context) bool {
+	ci, _ := strconv.ParseBool(os.Getenv("CI"))
+	return ci || yesFlag(c)
+}
+
+// dryRun reports whether --dry-run was given, either before or after the
+// command name
+func dryRun(c *cli.Context) bool {
+	for _, ctx := range c.Lineage() {
+		if ctx.Bool("dry-run") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeErrorJSON writes err as a JSON object with its code and version
+func writeErrorJSON(w io.Writer, err error) {
+	out := struct {
+		Error   string `json:"error"`
+		Code    string `json:"code"`
+		Version string `json:"version,omitempty"`
+	}{
+		Error: err.Error(),
+		Code:  migration.CodeUnknown,
+	}
+
+	var merr *migration.Error
+	if errors.As(err, &merr) {
+		out.Code = merr.Code
+		out.Version = merr.Version
+	}
+
+	json.NewEncoder(w).Encode(out)
 }
 
 func createCommand(c *cli.Context) error {
@@ -110,81 +1046,880 @@ func createCommand(c *cli.Context) error {
 	}
 
 	name := c.Args().First()
-	cfg := buildConfig(c)
+	cfg, err := buildConfig(c)
+	if err != nil {
+		return err
+	}
 
 	// Ensure migrations directory exists
-	if err := cfg.EnsureMigrationsDir(); err != nil {
-		return fmt.Errorf("failed to create migrations directory: %w", err)
+	if !dryRun(c) {
+		if err := cfg.EnsureMigrationsDir(); err != nil {
+			return fmt.Errorf("failed to create migrations directory: %w", err)
+		}
 	}
 
-	// Create storage (we don't need it for creating files, but validate connection)
-	store, err := storage.New(cfg.DatabaseURL, cfg.AuthToken)
+	// Creating a file never touches the database
+	engine, err := newEngine(nil, cfg)
 	if err != nil {
-		return fmt.Errorf("failed to connect to Turso database: %w", err)
+		return err
 	}
-	defer store.Close()
-
-	engine := migration.NewEngine(store, cfg.MigrationsDir)
-	return engine.Create(name)
+	engine.SetVersionWidth(c.Int("version-width"))
+	return engine.Create(name, migration.CreateOptions{
+		DryRun:   dryRun(c),
+		NoDown:   c.Bool("no-down"),
+		SQLOnly:  c.Bool("sql-only"),
+		Template: c.String("template"),
+		Empty:    c.Bool("empty"),
+		Note:     c.String("note"),
+		Dir:      c.String("dir"),
+	})
 }
 
 func upCommand(c *cli.Context) error {
-	cfg := buildConfig(c)
+	cfg, err := buildConfig(c)
+	if err != nil {
+		return err
+	}
 
-	store, err := storage.New(cfg.DatabaseURL, cfg.AuthToken)
+	opts := migration.UpOptions{
+		Approve:         c.String("approve"),
+		Concurrency:     c.Int("concurrency"),
+		AssertSchema:    c.String("assert-schema"),
+		ApplyTimeout:    c.Duration("apply-timeout"),
+		GitRef:          c.String("git-ref"),
+		JSON:            c.Bool("json"),
+		DryRun:          dryRun(c),
+		StrictChecksums: c.Bool("strict-checksums"),
+		Atomic:          c.Bool("atomic"),
+		Strict:          c.Bool("strict"),
+		Limit:           c.Int("limit"),
+		Check:           c.Bool("check"),
+		Only:            c.String("only"),
+		Force:           c.Bool("force"),
+	}
+	if opts.Only != "" && (c.IsSet("shard") || opts.Limit > 0 || c.IsSet("after-date")) {
+		return fmt.Errorf("--only cannot be combined with --shard, --limit or --after-date")
+	}
+	if opts.Force && opts.Only == "" {
+		return fmt.Errorf("--force needs --only")
+	}
+	if opts.Force && opts.Strict {
+		return fmt.Errorf("--force cannot be combined with --strict")
+	}
+	if opts.Only != "" && !opts.DryRun && !assumeYes(c) {
+		opts.Input = os.Stdin
+		opts.Phrase = confirmationPhrase(cfg)
+	}
+	if opts.BeforeHook, err = hookSQL(cfg.BeforeHook); err != nil {
+		return err
+	}
+	if opts.AfterHook, err = hookSQL(cfg.AfterHook); err != nil {
+		return err
+	}
+	if opts.Limit < 0 {
+		return fmt.Errorf("--limit must be zero or positive")
+	}
+	if opts.JSON && opts.DryRun {
+		return fmt.Errorf("--json cannot be combined with --dry-run")
+	}
+	if value := c.String("after-date"); value != "" {
+		opts.AfterDate, err = parseDate(value)
+		if err != nil {
+			return err
+		}
+	}
+	if spec := c.String("shard"); spec != "" {
+		opts.Shard, opts.Shards, err = migration.ParseShard(spec)
+		if err != nil {
+			return err
+		}
+	}
+
+	open := openStorage
+	if dryRun(c) {
+		open = openReadOnlyStorage
+	}
+	store, err := open(cfg)
 	if err != nil {
 		return fmt.Errorf("failed to initialize storage: %w", err)
 	}
 	defer store.Close()
 
-	engine := migration.NewEngine(store, cfg.MigrationsDir)
-	return engine.Up()
+	engine, err := newEngine(store, cfg)
+	if err != nil {
+		return err
+	}
+	engine.SetStreaming(c.Bool("stream"))
+	return engine.UpContext(c.Context, opts)
+}
+
+// confirmDestructive asks for the configured confirmation phrase before a
+// destructive command runs. Nothing is asked when no phrase is configured
+// or --yes is given.
+func confirmDestructive(c *cli.Context, cfg *config.Config, prompt string) error {
+	if cfg.ConfirmPhrase == "" || assumeYes(c) {
+		return nil
+	}
+
+	ok, err := migration.Confirm(bufio.NewReader(os.Stdin), os.Stderr, prompt, confirmationPhrase(cfg))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("confirmation phrase did not match; nothing was changed")
+	}
+	return nil
+}
+
+// confirmationPhrase returns the configured confirmation phrase with
+// {database} replaced by the database name
+func confirmationPhrase(cfg *config.Config) string {
+	return strings.ReplaceAll(cfg.ConfirmPhrase, "{database}", databaseName(cfg.DatabaseURL))
+}
+
+// databaseName returns the name of the database in a connection string,
+// the first label of its host for remote databases
+func databaseName(connStr string) string {
+	u, err := url.Parse(connStr)
+	if err != nil {
+		return connStr
+	}
+	if u.Host == "" {
+		return filepath.Base(u.Opaque + u.Path)
+	}
+	name, _, _ := strings.Cut(u.Hostname(), ".")
+	return name
+}
+
+// parseDate parses a YYYY-MM-DD date in local time or an RFC 3339 timestamp
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: use YYYY-MM-DD or RFC 3339", value)
+	}
+	return t, nil
 }
 
 func downCommand(c *cli.Context) error {
-	cfg := buildConfig(c)
+	cfg, err := buildConfig(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("last-run") && (c.IsSet("from-file") || c.IsSet("version") || c.IsSet("steps")) {
+		return fmt.Errorf("--last-run cannot be combined with --from-file, --version or --steps")
+	}
+	if c.IsSet("to") && (c.Bool("last-run") || c.IsSet("from-file") || c.IsSet("version") || c.IsSet("steps")) {
+		return fmt.Errorf("--to cannot be combined with --last-run, --from-file, --version or --steps")
+	}
+	if c.Int("steps") < 1 {
+		return fmt.Errorf("--steps must be at least 1")
+	}
+	if c.Int("steps") > 1 && c.IsSet("from-file") {
+		return fmt.Errorf("--from-file can only be used when rolling back one migration")
+	}
+	if c.Bool("skip-missing-down") && !yesFlag(c) && !dryRun(c) {
+		return fmt.Errorf("--skip-missing-down leaves schema changes in place while forgetting them; confirm with --yes")
+	}
 
-	store, err := storage.New(cfg.DatabaseURL, cfg.AuthToken)
+	var input io.Reader
+	if !dryRun(c) && !assumeYes(c) {
+		input = os.Stdin
+	}
+
+	open := openStorage
+	if dryRun(c) {
+		open = openReadOnlyStorage
+	}
+	store, err := open(cfg)
 	if err != nil {
 		return fmt.Errorf("failed to initialize storage: %w", err)
 	}
 	defer store.Close()
 
-	engine := migration.NewEngine(store, cfg.MigrationsDir)
-	return engine.Down()
+	engine, err := newEngine(store, cfg)
+	if err != nil {
+		return err
+	}
+	return engine.DownContext(c.Context, migration.DownOptions{
+		FromFile:        c.String("from-file"),
+		Version:         c.String("version"),
+		Steps:           c.Int("steps"),
+		Soft:            c.Bool("soft-down"),
+		LastRun:         c.Bool("last-run"),
+		To:              c.String("to"),
+		DryRun:          dryRun(c),
+		SkipMissingDown: c.Bool("skip-missing-down"),
+		Input:           input,
+		Phrase:          confirmationPhrase(cfg),
+	})
+}
+
+func gotoCommand(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return fmt.Errorf("target version is required")
+	}
+
+	cfg, err := buildConfig(c)
+	if err != nil {
+		return err
+	}
+
+	target := c.Args().First()
+	if err := confirmDestructive(c, cfg, "This may roll back migrations on "+databaseName(cfg.DatabaseURL)+" to reach version "+target+"."); err != nil {
+		return err
+	}
+
+	store, err := openStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer store.Close()
+
+	engine, err := newEngine(store, cfg)
+	if err != nil {
+		return err
+	}
+	return engine.GotoContext(c.Context, target)
 }
 
 func statusCommand(c *cli.Context) error {
-	cfg := buildConfig(c)
+	cfg, err := buildConfig(c)
+	if err != nil {
+		return err
+	}
 
-	store, err := storage.New(cfg.DatabaseURL, cfg.AuthToken)
+	store, err := openStorage(cfg)
 	if err != nil {
 		return fmt.Errorf("failed to initialize storage: %w", err)
 	}
 	defer store.Close()
 
-	engine := migration.NewEngine(store, cfg.MigrationsDir)
-	return engine.Status()
+	engine, err := newEngine(store, cfg)
+	if err != nil {
+		return err
+	}
+	return engine.Status(migration.StatusOptions{
+		PreviewLatest: c.Bool("preview-latest"),
+		JSON:          c.Bool("json"),
+		Durations:     c.Bool("durations"),
+		Summary:       c.Bool("summary"),
+	})
 }
 
 func versionCommand(c *cli.Context) error {
-	cfg := buildConfig(c)
+	cfg, err := buildConfig(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("print-applied-versions") {
+		order := c.String("order")
+		if order != "asc" && order != "desc" {
+			return fmt.Errorf("invalid --order %q: must be asc or desc", order)
+		}
 
-	store, err := storage.New(cfg.DatabaseURL, cfg.AuthToken)
+		store, err := openReadOnlyStorage(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize storage: %w", err)
+		}
+		defer store.Close()
+
+		engine, err := newEngine(store, cfg)
+		if err != nil {
+			return err
+		}
+		return engine.PrintAppliedVersions(order == "desc")
+	}
+
+	store, err := openStorage(cfg)
 	if err != nil {
 		return fmt.Errorf("failed to initialize storage: %w", err)
 	}
 	defer store.Close()
 
-	engine := migration.NewEngine(store, cfg.MigrationsDir)
+	engine, err := newEngine(store, cfg)
+	if err != nil {
+		return err
+	}
 	return engine.Version()
 }
 
-func buildConfig(c *cli.Context) *config.Config {
+func snapshotCommand(c *cli.Context) error {
+	cfg, err := buildConfig(c)
+	if err != nil {
+		return err
+	}
+
+	tables := c.StringSlice("tables")
+	name := c.Args().First()
+	if name == "" {
+		name = "snapshot_" + strings.Join(tables, "_")
+	}
+
+	store, err := openStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer store.Close()
+
+	engine, err := newEngine(store, cfg)
+	if err != nil {
+		return err
+	}
+	return engine.Snapshot(name, migration.SnapshotOptions{
+		Tables:  tables,
+		MaxRows: c.Int("max-rows"),
+		DryRun:  dryRun(c),
+	})
+}
+
+func changelogCommand(c *cli.Context) error {
+	cfg, err := buildConfig(c)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer store.Close()
+
+	engine, err := newEngine(store, cfg)
+	if err != nil {
+		return err
+	}
+
+	if path := c.String("output"); path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", path, err)
+		}
+		defer f.Close()
+		engine.SetOutput(f)
+	}
+
+	return engine.Changelog()
+}
+
+func whoamiCommand(c *cli.Context) error {
+	cfg, err := buildConfig(c)
+	if err != nil {
+		return err
+	}
+
+	store, err := openReadOnlyStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer store.Close()
+
+	info, err := store.Info()
+	if err != nil {
+		return fmt.Errorf("failed to query database: %w", err)
+	}
+
+	connection := "remote (primary/replica not reported by the server)"
+	if !info.Remote {
+		connection = "local file"
+	}
+
+	out := commandOutput(c, cfg.Quiet)
+	fmt.Fprintf(out, "Database:       %s\n", info.URL)
+	fmt.Fprintf(out, "Host:           %s\n", info.Host)
+	fmt.Fprintf(out, "Connection:     %s\n", connection)
+	fmt.Fprintf(out, "SQLite version: %s\n", info.SQLiteVersion)
+	switch {
+	case !info.HasTrackingTable:
+		fmt.Fprintln(out, "Schema version: none (no migrations table)")
+	case info.CurrentVersion == "":
+		fmt.Fprintln(out, "Schema version: none (no migrations applied)")
+	default:
+		fmt.Fprintf(out, "Schema version: %s\n", info.CurrentVersion)
+	}
+
+	return nil
+}
+
+// doctorCheck is the outcome of one doctor check
+type doctorCheck struct {
+	name    string
+	ok      bool
+	skipped bool
+	detail  string
+	// fix suggests how to resolve a failure
+	fix string
+}
+
+// skippedCheck is a check not run because one it depends on failed
+func skippedCheck(name, reason string) doctorCheck {
+	return doctorCheck{name: name, ok: true, skipped: true, detail: "skipped, " + reason}
+}
+
+func doctorCommand(c *cli.Context) error {
+	var checks []doctorCheck
+	// token is masked in every detail printed
+	var token string
+	out := commandOutput(c, c.Bool("quiet"))
+	report := func() error {
+		failed := 0
+		for _, check := range checks {
+			mark := "✓"
+			switch {
+			case check.skipped:
+				mark = "-"
+			case !check.ok:
+				mark = "✗"
+				failed++
+			}
+			fmt.Fprintf(out, "%s %s: %s\n", mark, check.name, storage.Redact(check.detail, token))
+			if !check.ok && check.fix != "" {
+				fmt.Fprintf(out, "    %s\n", check.fix)
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+		}
+		fmt.Fprintln(out, "All checks passed")
+		return nil
+	}
+
+	cfg, err := buildConfig(c)
+	if err != nil {
+		checks = append(checks, doctorCheck{name: "Configuration", detail: err.Error(),
+			fix: "Fix the flags, environment variables or config file named in the error"})
+		return report()
+	}
+
+	token = cfg.AuthToken
+	out = commandOutput(c, cfg.Quiet)
+
+	urlCheck := doctorURL(cfg.DatabaseURL)
+	checks = append(checks, urlCheck)
+
+	tokenCheck := doctorCheck{name: "Auth token", ok: true}
+	switch {
+	case storage.IsLocalURL(cfg.DatabaseURL):
+		tokenCheck.detail = "not needed for a local database"
+	case cfg.AuthToken == "":
+		tokenCheck.ok = false
+		tokenCheck.detail = "not set"
+		tokenCheck.fix = "Set TURSO_AUTH_TOKEN, --auth-token or --auth-token-file; create a token with: turso db tokens create <database>"
+	case flagSet(c, "auth-token-file"):
+		tokenCheck.detail = "read from " + lineageString(c, "auth-token-file")
+	default:
+		tokenCheck.detail = "set"
+	}
+	checks = append(checks, tokenCheck)
+
+	checks = append(checks, doctorDir(cfg.MigrationsDir))
+
+	if !urlCheck.ok || !tokenCheck.ok {
+		checks = append(checks,
+			skippedCheck("Connection", "the database URL or auth token is missing or invalid"),
+			skippedCheck("Migrations table", "no connection"))
+		return report()
+	}
+
+	store, err := openReadOnlyStorage(cfg)
+	if err != nil {
+		fix := "Check that the database URL is correct and the server is reachable"
+		switch {
+		case errors.Is(err, storage.ErrAuth):
+			fix = "The auth token was rejected; it may have expired or belong to another database. Create a new one with: turso db tokens create <database>"
+		case errors.Is(err, storage.ErrUnreachable):
+			fix = "Check your network connection and that the database exists: turso db list"
+		}
+		checks = append(checks,
+			doctorCheck{name: "Connection", detail: err.Error(), fix: fix},
+			skippedCheck("Migrations table", "no connection"))
+		return report()
+	}
+	defer store.Close()
+
+	table := cfg.Table
+	if table == "" {
+		table = storage.DefaultTable
+	}
+
+	// Opening the storage pinged the database, so any error from here on
+	// is about the migrations table
+	info, err := store.Info()
+	checks = append(checks, doctorCheck{name: "Connection", ok: true, detail: "connected to " + info.Host})
+	if err != nil {
+		checks = append(checks, doctorCheck{name: "Migrations table", detail: err.Error(),
+			fix: fmt.Sprintf("Check that the auth token is allowed to read %s", table)})
+		return report()
+	}
+
+	tableCheck := doctorCheck{name: "Migrations table", ok: true}
+	switch {
+	case !info.HasTrackingTable:
+		tableCheck.detail = fmt.Sprintf("%s does not exist yet; up creates it", table)
+	case info.CurrentVersion == "":
+		tableCheck.detail = fmt.Sprintf("%s is readable, no migrations applied", table)
+	default:
+		tableCheck.detail = fmt.Sprintf("%s is readable, at version %s", table, info.CurrentVersion)
+	}
+	checks = append(checks, tableCheck)
+
+	return report()
+}
+
+// doctorURL checks the format of the database URL
+func doctorURL(databaseURL string) doctorCheck {
+	check := doctorCheck{name: "Database URL", fix: "Set TURSO_DATABASE_URL or --database-url to a URL such as libsql://<database>-<org>.turso.io; see it with: turso db show <database>"}
+	if databaseURL == "" {
+		check.detail = "not set"
+		return check
+	}
+	if storage.IsLocalURL(databaseURL) {
+		check.ok = true
+		check.detail = "local database " + databaseURL
+		return check
+	}
+
+	u, err := url.Parse(databaseURL)
+	if err != nil {
+		check.detail = fmt.Sprintf("cannot be parsed: %v", err)
+		return check
+	}
+	switch u.Scheme {
+	case "libsql", "http", "https", "ws", "wss":
+	default:
+		check.detail = fmt.Sprintf("unsupported scheme %q in %s", u.Scheme, u.Redacted())
+		return check
+	}
+	if u.Host == "" {
+		check.detail = "no host in " + u.Redacted()
+		return check
+	}
+	if u.Query().Has("authToken") {
+		check.detail = "contains an auth token"
+		check.fix = "Remove authToken from the URL and pass it with --auth-token or --auth-token-file instead"
+		return check
+	}
+	check.ok = true
+	check.detail = u.Redacted()
+	return check
+}
+
+// doctorDir checks that the migrations directory exists and can be read
+func doctorDir(dir string) doctorCheck {
+	check := doctorCheck{name: "Migrations directory"}
+	info, err := os.Stat(dir)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		check.detail = dir + " does not exist"
+		check.fix = "Create it with: turso-migrate create <name>, or point --migrations-dir at your migrations"
+		return check
+	case err != nil:
+		check.detail = err.Error()
+		check.fix = "Check the permissions of " + dir
+		return check
+	case !info.IsDir():
+		check.detail = dir + " is not a directory"
+		check.fix = "Point --migrations-dir at the directory holding your migrations"
+		return check
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		check.detail = err.Error()
+		check.fix = "Check the permissions of " + dir
+		return check
+	}
+	sqlFiles := 0
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".sql") {
+			sqlFiles++
+		}
+	}
+	check.ok = true
+	check.detail = fmt.Sprintf("%s, %d .sql files", dir, sqlFiles)
+	return check
+}
+
+func baselineCommand(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return fmt.Errorf("baseline version is required")
+	}
+
+	cfg, err := buildConfig(c)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer store.Close()
+
+	engine, err := newEngine(store, cfg)
+	if err != nil {
+		return err
+	}
+	return engine.Baseline(c.Args().First())
+}
+
+func resetCommand(c *cli.Context) error {
+	cfg, err := buildConfig(c)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer store.Close()
+
+	engine, err := newEngine(store, cfg)
+	if err != nil {
+		return err
+	}
+
+	// Resetting is never confirmed implicitly, so CI=true doesn't apply
+	opts := migration.ResetOptions{Hard: c.Bool("hard"), Phrase: confirmationPhrase(cfg)}
+	if opts.Phrase == "" {
+		opts.Phrase = databaseName(cfg.DatabaseURL)
+	}
+	if !yesFlag(c) {
+		opts.Input = os.Stdin
+	}
+	return engine.ResetContext(c.Context, opts)
+}
+
+func repairCommand(c *cli.Context) error {
+	cfg, err := buildConfig(c)
+	if err != nil {
+		return err
+	}
+
+	prune := c.Bool("prune")
+	open := openStorage
+	if !prune {
+		open = openReadOnlyStorage
+	}
+	store, err := open(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer store.Close()
+
+	engine, err := newEngine(store, cfg)
+	if err != nil {
+		return err
+	}
+	opts := migration.RepairOptions{Prune: prune, Phrase: confirmationPhrase(cfg)}
+	if !assumeYes(c) {
+		opts.Input = os.Stdin
+	}
+	return engine.Repair(opts)
+}
+
+func forceUnlockCommand(c *cli.Context) error {
+	cfg, err := buildConfig(c)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer store.Close()
+
+	engine, err := newEngine(store, cfg)
+	if err != nil {
+		return err
+	}
+	return engine.ForceUnlock(migration.ForceUnlockOptions{
+		Yes:    assumeYes(c),
+		Input:  os.Stdin,
+		Phrase: confirmationPhrase(cfg),
+	})
+}
+
+func validateCommand(c *cli.Context) error {
+	cfg, err := buildConfig(c)
+	if err != nil {
+		return err
+	}
+
+	engine, err := newEngine(nil, cfg)
+	if err != nil {
+		return err
+	}
+	return engine.Validate(migration.ValidateOptions{
+		ParseOnly: c.Bool("parse-only"),
+		OnlyNew:   c.Bool("only-new"),
+		FailFast:  c.Bool("fail-fast"),
+	})
+}
+
+func listFilesCommand(c *cli.Context) error {
+	cfg, err := buildConfig(c)
+	if err != nil {
+		return err
+	}
+
+	engine, err := newEngine(nil, cfg)
+	if err != nil {
+		return err
+	}
+	return engine.ListFiles(migration.ListFilesOptions{
+		JSON: c.Bool("json"),
+	})
+}
+
+func manifestGenerateCommand(c *cli.Context) error {
+	engine, path, err := manifestEngine(c)
+	if err != nil {
+		return err
+	}
+	return engine.GenerateManifest(path)
+}
+
+func manifestVerifyCommand(c *cli.Context) error {
+	engine, path, err := manifestEngine(c)
+	if err != nil {
+		return err
+	}
+	return engine.VerifyManifest(path)
+}
+
+// manifestEngine returns an engine without a database and the manifest path
+func manifestEngine(c *cli.Context) (*migration.Engine, string, error) {
+	cfg, err := buildConfig(c)
+	if err != nil {
+		return nil, "", err
+	}
+
+	engine, err := newEngine(nil, cfg)
+	if err != nil {
+		return nil, "", err
+	}
+
+	path := c.String("file")
+	if path == "" {
+		path = filepath.Join(cfg.MigrationsDir, migration.DefaultManifestFile)
+	}
+	return engine, path, nil
+}
+
+func tuiCommand(c *cli.Context) error {
+	cfg, err := buildConfig(c)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer store.Close()
+
+	engine, err := newEngine(store, cfg)
+	if err != nil {
+		return err
+	}
+	return engine.Interactive(os.Stdin)
+}
+
+// openStorage connects to the Turso database described by cfg
+func openStorage(cfg *config.Config) (*storage.TursoStorage, error) {
+	return migration.OpenStorage(cfg, false)
+}
+
+// openReadOnlyStorage connects without creating or altering the tracking
+// table, so read-only credentials can be used
+func openReadOnlyStorage(cfg *config.Config) (*storage.TursoStorage, error) {
+	return migration.OpenStorage(cfg, true)
+}
+
+// newEngine creates a migration engine configured from cfg
+func newEngine(store *storage.TursoStorage, cfg *config.Config) (*migration.Engine, error) {
+	engine := migration.NewEngine(store, cfg.MigrationsDir)
+	color, err := migration.ColorEnabled(cfg.Color, os.Stdout)
+	if err != nil {
+		return nil, err
+	}
+	engine.SetColor(color)
+	if cfg.Quiet {
+		engine.SetOutput(io.Discard)
+	}
+	if err := engine.Configure(cfg); err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
+
+// commandOutput returns the writer for the normal output of a command that
+// doesn't run an engine: the app's writer, or io.Discard when quiet, as
+// newEngine does for the engine's output
+func commandOutput(c *cli.Context, quiet bool) io.Writer {
+	if quiet {
+		return io.Discard
+	}
+	return c.App.Writer
+}
+
+func buildConfig(c *cli.Context) (*config.Config, error) {
+	maxSize, err := config.ParseSize(c.String("max-migration-size"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid --max-migration-size: %w", err)
+	}
+
 	cfg := &config.Config{
-		DatabaseURL:   c.String("database-url"),
-		AuthToken:     c.String("auth-token"),
-		MigrationsDir: c.String("migrations-dir"),
+		DatabaseURL:       lineageString(c, "database-url"),
+		AuthToken:         lineageString(c, "auth-token"),
+		AuthTokenFile:     lineageString(c, "auth-token-file"),
+		MigrationsDir:     lineageString(c, "migrations-dir"),
+		MaxMigrationSize:  maxSize,
+		Compat:            c.String("compat"),
+		VersionFormat:     c.String("version-format"),
+		Namespace:         c.String("namespace"),
+		TrackingDB:        c.String("tracking-db"),
+		Dialect:           c.String("dialect"),
+		Color:             c.String("color"),
+		MinVersion:        c.String("min-version"),
+		ReadOnly:          c.Bool("read-only"),
+		Quiet:             c.Bool("quiet"),
+		Verbose:           c.Bool("verbose"),
+		SkipInvalid:       c.Bool("skip-invalid"),
+		ExpandEnv:         c.Bool("expand-env"),
+		ConfirmPhrase:     c.String("confirm-phrase"),
+		LockTable:         c.String("lock-table"),
+		LockTimeout:       c.Duration("lock-timeout"),
+		LockRetryInterval: c.Duration("lock-retry-interval"),
+		LockStaleAfter:    c.Duration("lock-stale-after"),
+		ExecRetries:       c.Int("exec-retries"),
+		ExecRetryDelay:    c.Duration("exec-retry-delay"),
+		Timeout:           c.Duration("timeout"),
+		BeforeHook:        c.String("before-hook"),
+		AfterHook:         c.String("after-hook"),
+	}
+
+	if err := applyConfigFile(c, cfg); err != nil {
+		return nil, err
+	}
+
+	if c.Bool("no-color") {
+		cfg.Color = migration.ColorNever
+	}
+
+	// An explicit pattern overrides the one implied by --compat
+	if c.IsSet("filename-pattern") {
+		cfg.FilenamePattern = c.String("filename-pattern")
+	}
+	if cfg.Table == "" {
+		cfg.Table = migration.CompatTable(cfg.Compat)
 	}
 
 	// Load from environment if not provided via flags
@@ -194,9 +1929,21 @@ func buildConfig(c *cli.Context) *config.Config {
 	if cfg.AuthToken == "" {
 		cfg.AuthToken = os.Getenv("TURSO_AUTH_TOKEN")
 	}
+	if err := cfg.ResolveAuthToken(); err != nil {
+		return nil, err
+	}
 	if cfg.MigrationsDir == "" {
 		cfg.MigrationsDir = "./migrations"
 	}
+	if cfg.Quiet && cfg.Verbose {
+		return nil, fmt.Errorf("--quiet and --verbose cannot be combined")
+	}
+	if cfg.Namespace != "" {
+		if !namespacePattern.MatchString(cfg.Namespace) {
+			return nil, fmt.Errorf("invalid --namespace %q: use letters, digits and underscores", cfg.Namespace)
+		}
+		cfg.MigrationsDir = filepath.Join(cfg.MigrationsDir, cfg.Namespace)
+	}
 
-	return cfg
+	return cfg, nil
 }
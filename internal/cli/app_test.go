@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// run runs the CLI with args, returning what it wrote to its writer
+func run(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	app := NewApp()
+	var out bytes.Buffer
+	app.Writer = &out
+	err := app.Run(append([]string{"turso-migrate"}, args...))
+	return out.String(), err
+}
+
+func TestWhoamiOutput(t *testing.T) {
+	url := "file:" + filepath.Join(t.TempDir(), "test.db")
+
+	out, err := run(t, "--database-url", url, "whoami")
+	if err != nil {
+		t.Fatalf("whoami: %v", err)
+	}
+	if !strings.Contains(out, "Connection:     local file") {
+		t.Errorf("whoami output = %q, want the connection", out)
+	}
+
+	out, err = run(t, "--quiet", "--database-url", url, "whoami")
+	if err != nil {
+		t.Fatalf("whoami --quiet: %v", err)
+	}
+	if out != "" {
+		t.Errorf("whoami --quiet output = %q, want nothing", out)
+	}
+}
+
+func TestDoctorOutput(t *testing.T) {
+	dir := t.TempDir()
+	url := "file:" + filepath.Join(dir, "test.db")
+
+	out, err := run(t, "--database-url", url, "--migrations-dir", dir, "doctor")
+	if err != nil {
+		t.Fatalf("doctor: %v\n%s", err, out)
+	}
+	for _, want := range []string{"schema_migrations does not exist yet", "All checks passed"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("doctor output = %q, want %q", out, want)
+		}
+	}
+
+	out, err = run(t, "--quiet", "--database-url", url, "--migrations-dir", dir, "doctor")
+	if err != nil {
+		t.Fatalf("doctor --quiet: %v", err)
+	}
+	if out != "" {
+		t.Errorf("doctor --quiet output = %q, want nothing", out)
+	}
+}
@@ -0,0 +1,73 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+)
+
+// Baseline records every migration file up to and including version as
+// applied without running any SQL, for adopting the tool on a database
+// whose schema already matches them. It refuses to run once any migration
+// is recorded, so it can't create duplicate records.
+func (e *Engine) Baseline(version string) error {
+	if err := e.checkWritable("baseline migrations"); err != nil {
+		return err
+	}
+
+	files, err := e.loadMigrationFiles()
+	if err != nil {
+		return fmt.Errorf("failed to load migration files: %w", err)
+	}
+
+	var baseline []MigrationFile
+	found := false
+	for _, file := range files {
+		if versionLess(version, file.Version) {
+			break
+		}
+		baseline = append(baseline, file)
+		found = found || file.Version == version
+	}
+	if !found {
+		return newError(CodeFileNotFound, version, nil, "no migration file for baseline version %s", version)
+	}
+
+	ctx := context.Background()
+	if err := e.lock(ctx); err != nil {
+		return err
+	}
+	defer e.unlock()
+
+	applied, err := e.storage.GetAppliedMigrationsContext(ctx)
+	if err != nil {
+		return newError(CodeStorageFailed, "", err, "failed to get applied migrations")
+	}
+	if len(applied) > 0 {
+		return fmt.Errorf("cannot baseline: %d migration(s) are already recorded, the latest being %s", len(applied), applied[len(applied)-1].Version)
+	}
+
+	// Record them all or none, so a failure can simply be retried
+	tx, err := e.storage.BeginContext(ctx)
+	if err != nil {
+		return newError(CodeStorageFailed, "", err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	runID := newRunID()
+	for _, file := range baseline {
+		checksum, err := e.upChecksum(file)
+		if err != nil {
+			return newError(CodeRecordFailed, file.Version, err, "failed to checksum migration %s", file.Version)
+		}
+		if err := e.storage.RecordMigrationTxContext(ctx, tx, file.Version, file.Name, runID, checksum, file.Note, 0); err != nil {
+			return newError(CodeRecordFailed, file.Version, err, "failed to record migration %s", file.Version)
+		}
+		fmt.Fprintf(e.out, "Baselined migration %s: %s\n", file.Version, file.Name)
+	}
+	if err := tx.Commit(); err != nil {
+		return newError(CodeRecordFailed, "", err, "failed to commit baseline")
+	}
+
+	fmt.Fprintf(e.out, "Recorded %d migration(s) up to %s as applied without running them\n", len(baseline), version)
+	return nil
+}
@@ -0,0 +1,75 @@
+package migration
+
+import (
+	"fmt"
+)
+
+// Changelog writes a Markdown list of migrations grouped by the date they
+// were applied, newest first, with pending migrations under "Unreleased"
+func (e *Engine) Changelog() error {
+	files, err := e.loadMigrationFiles()
+	if err != nil {
+		return fmt.Errorf("failed to load migration files: %w", err)
+	}
+
+	applied, err := e.storage.GetAppliedMigrations()
+	if err != nil {
+		return newError(CodeStorageFailed, "", err, "failed to get applied migrations")
+	}
+
+	descriptions := make(map[string]string)
+	appliedSet := make(map[string]bool)
+	for _, file := range files {
+		descriptions[file.Version] = file.Description
+	}
+	for _, m := range applied {
+		appliedSet[m.Version] = true
+	}
+
+	fmt.Fprintln(e.out, "# Schema Changelog")
+
+	var pending []MigrationFile
+	for _, file := range files {
+		if !appliedSet[file.Version] {
+			pending = append(pending, file)
+		}
+	}
+	if len(pending) > 0 {
+		fmt.Fprintln(e.out)
+		fmt.Fprintln(e.out, "## Unreleased")
+		fmt.Fprintln(e.out)
+		for i := len(pending) - 1; i >= 0; i-- {
+			fmt.Fprintln(e.out, changelogEntry(pending[i].Version, pending[i].Name, pending[i].Description))
+		}
+	}
+
+	// Applied migrations are ordered by version; walk them backwards so the
+	// newest changes come first
+	var date string
+	for i := len(applied) - 1; i >= 0; i-- {
+		m := applied[i]
+		if day := m.AppliedAt.Format("2006-01-02"); day != date {
+			date = day
+			fmt.Fprintln(e.out)
+			fmt.Fprintf(e.out, "## %s\n", date)
+			fmt.Fprintln(e.out)
+		}
+		fmt.Fprintln(e.out, changelogEntry(m.Version, m.Name, descriptions[m.Version]))
+	}
+
+	if len(pending) == 0 && len(applied) == 0 {
+		fmt.Fprintln(e.out)
+		fmt.Fprintln(e.out, "No migrations found")
+	}
+
+	return nil
+}
+
+// changelogEntry formats a single Markdown list item
+func changelogEntry(version, name, description string) string {
+	entry := fmt.Sprintf("- `%s` %s", version, name)
+	if description != "" {
+		entry += ": " + description
+	}
+	return entry
+}
@@ -0,0 +1,70 @@
+package migration
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rubenmeza/turso-migrate/internal/storage"
+)
+
+// upChecksum returns the hex-encoded SHA-256 of a migration's UP SQL. In
+// streaming mode the UP section is read from disk to compute it. Line
+// endings are normalized first, so a file has the same checksum however it
+// is read, and with environment expansion the SQL is expanded, so applying
+// it with different values counts as a change.
+func (e *Engine) upChecksum(file MigrationFile) (string, error) {
+	upSQL := file.UpSQL
+	if e.streaming {
+		r, err := e.openUp(file)
+		if err != nil {
+			return "", err
+		}
+		defer r.Close()
+
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return "", err
+		}
+		upSQL = string(content)
+	}
+	upSQL, err := e.expandSQL(upSQL)
+	if err != nil {
+		return "", err
+	}
+	upSQL = strings.TrimSpace(strings.ReplaceAll(upSQL, "\r\n", "\n"))
+
+	sum := sha256.Sum256([]byte(upSQL))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// modifiedMigrations returns the versions of applied migrations whose UP
+// SQL changed since they were applied, warning about each one. Migrations
+// recorded without a checksum or whose file is missing are not checked.
+func (e *Engine) modifiedMigrations(files []MigrationFile, applied []storage.Migration) ([]string, error) {
+	byVersion := make(map[string]MigrationFile)
+	for _, file := range files {
+		byVersion[file.Version] = file
+	}
+
+	var modified []string
+	for _, m := range applied {
+		file, ok := byVersion[m.Version]
+		if !ok || m.Checksum == "" {
+			continue
+		}
+
+		sum, err := e.upChecksum(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to checksum %s: %w", file.Path, err)
+		}
+		if sum != m.Checksum {
+			fmt.Fprintf(e.out, "%s migration %s (%s) was modified after it was applied\n",
+				e.paint(colorRed, "WARNING:"), m.Version, m.Name)
+			modified = append(modified, m.Version)
+		}
+	}
+	return modified, nil
+}
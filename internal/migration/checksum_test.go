@@ -0,0 +1,43 @@
+package migration
+
+import (
+	"io"
+	"testing"
+)
+
+func TestUpChecksumSameWhenStreaming(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"001_no_markers.sql": "CREATE TABLE users (id INTEGER);\r\nCREATE INDEX users_id ON users (id);\r\n",
+		"002_markers.sql":    "-- ==== UP ====\r\nCREATE TABLE posts (id INTEGER);\r\n-- ==== DOWN ====\r\nDROP TABLE posts;\r\n",
+	})
+
+	checksums := func(streaming bool) []string {
+		e := NewEngine(nil, dir)
+		e.SetOutput(io.Discard)
+		e.SetStreaming(streaming)
+		files, err := e.loadMigrationFiles()
+		if err != nil {
+			t.Fatalf("loadMigrationFiles: %v", err)
+		}
+		var sums []string
+		for _, file := range files {
+			sum, err := e.upChecksum(file)
+			if err != nil {
+				t.Fatalf("upChecksum(%s): %v", file.Version, err)
+			}
+			sums = append(sums, sum)
+		}
+		return sums
+	}
+
+	loaded, streamed := checksums(false), checksums(true)
+	if len(loaded) != 2 || len(streamed) != 2 {
+		t.Fatalf("got %d and %d checksums, want 2 each", len(loaded), len(streamed))
+	}
+	for i := range loaded {
+		if loaded[i] != streamed[i] {
+			t.Errorf("file %d: checksum %s when loaded, %s when streamed", i+1, loaded[i], streamed[i])
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package migration
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Color modes accepted by ColorEnabled
+const (
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+// ANSI color codes used in output
+const (
+	colorRed    = "31"
+	colorGreen  = "32"
+	colorYellow = "33"
+)
+
+// ColorEnabled decides whether output written to w is colorized. In auto
+// mode colors are used only when w is a terminal, and a non-empty NO_COLOR
+// environment variable disables them in every mode.
+func ColorEnabled(mode string, w io.Writer) (bool, error) {
+	switch mode {
+	case ColorAlways, ColorNever, ColorAuto, "":
+	default:
+		return false, fmt.Errorf("invalid color mode %q: must be always, auto or never", mode)
+	}
+
+	if os.Getenv("NO_COLOR") != "" {
+		return false, nil
+	}
+
+	switch mode {
+	case ColorAlways:
+		return true, nil
+	case ColorNever:
+		return false, nil
+	}
+
+	f, ok := w.(*os.File)
+	if !ok {
+		return false, nil
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false, nil
+	}
+	return info.Mode()&os.ModeCharDevice != 0, nil
+}
+
+// SetColor enables ANSI colors in output
+func (e *Engine) SetColor(enabled bool) {
+	e.color = enabled
+}
+
+// paint wraps s in the ANSI color code if colors are enabled
+func (e *Engine) paint(code, s string) string {
+	if !e.color {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
@@ -0,0 +1,48 @@
+package migration
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Supported compat modes
+const (
+	// CompatFlyway reads Flyway-style V<version>__<name>.sql migrations
+	CompatFlyway = "flyway"
+	// CompatGoose reads goose migrations with "-- +goose Up/Down" markers
+	CompatGoose = "goose"
+)
+
+// flywayTable is the tracking table used in Flyway compatibility mode
+const flywayTable = "flyway_schema_history"
+
+// SetCompat configures the engine to read migrations laid out for another
+// migration tool. An empty mode keeps the native format.
+func (e *Engine) SetCompat(mode string) error {
+	switch mode {
+	case "":
+	case CompatFlyway:
+		// Versioned migrations only; undo (U...) and repeatable (R__...)
+		// files are skipped
+		e.filenamePattern = regexp.MustCompile(`^V(?P<version>\d+)__(?P<name>.+)\.sql$`)
+		e.filenameFormat = "V%s__%s.sql"
+		e.ignorePattern = regexp.MustCompile(`^(U\d+__|R__).*\.sql$`)
+		e.upOnly = true
+	case CompatGoose:
+		// StatementBegin/StatementEnd blocks are kept intact by the
+		// statement splitter
+		e.markers = gooseMarkers
+	default:
+		return fmt.Errorf("unsupported compat mode %q", mode)
+	}
+	return nil
+}
+
+// CompatTable returns the tracking table used by a compat mode, or an empty
+// string for the default table
+func CompatTable(mode string) string {
+	if mode == CompatFlyway {
+		return flywayTable
+	}
+	return ""
+}
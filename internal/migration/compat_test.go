@@ -0,0 +1,106 @@
+package migration
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rubenmeza/turso-migrate/internal/storage"
+)
+
+// writeFiles creates files with the given contents in dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFlywayDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"V1__Create_users.sql":  "CREATE TABLE users (id INTEGER PRIMARY KEY);\n",
+		"V2__Add_email.sql":     "ALTER TABLE users ADD COLUMN email TEXT;\n-- ==== DOWN ====\nnot a down section;\n",
+		"U2__Add_email.sql":     "ALTER TABLE users DROP COLUMN email;\n",
+		"R__Views.sql":          "CREATE VIEW v AS SELECT 1;\n",
+		"001_native_format.sql": "-- ==== UP ====\nSELECT 1;\n",
+	})
+
+	e := NewEngine(nil, dir)
+	if err := e.SetCompat(CompatFlyway); err != nil {
+		t.Fatal(err)
+	}
+	e.SetSkipInvalid(true)
+	e.SetOutput(io.Discard)
+	e.SetWarningOutput(io.Discard)
+
+	files, err := e.loadMigrationFiles()
+	if err != nil {
+		t.Fatalf("loadMigrationFiles: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("loaded %d files, want V1 and V2 only", len(files))
+	}
+	if files[0].Version != "1" || files[0].Name != "Create_users" || files[1].Version != "2" || files[1].Name != "Add_email" {
+		t.Errorf("loaded %s_%s and %s_%s", files[0].Version, files[0].Name, files[1].Version, files[1].Name)
+	}
+	for _, f := range files {
+		if f.DownSQL != "" {
+			t.Errorf("%s has DOWN SQL %q, want Flyway files treated as UP-only", f.Path, f.DownSQL)
+		}
+	}
+	if want := "ALTER TABLE users ADD COLUMN email TEXT;\n-- ==== DOWN ====\nnot a down section;"; files[1].UpSQL != want {
+		t.Errorf("UP SQL of V2 = %q, want the whole file", files[1].UpSQL)
+	}
+}
+
+func TestGooseStatementBlock(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"00002_audit_trigger.sql": `-- +goose Up
+CREATE TABLE audit_log (id INTEGER PRIMARY KEY, user_id INTEGER, action TEXT);
+
+-- +goose StatementBegin
+CREATE TRIGGER users_audit AFTER UPDATE ON users
+BEGIN
+    INSERT INTO audit_log (user_id, action) VALUES (NEW.id, 'update');
+    UPDATE users SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TRIGGER users_audit;
+DROP TABLE audit_log;
+`,
+	})
+
+	e := NewEngine(nil, dir)
+	if err := e.SetCompat(CompatGoose); err != nil {
+		t.Fatal(err)
+	}
+	e.SetOutput(io.Discard)
+
+	files, err := e.loadMigrationFiles()
+	if err != nil {
+		t.Fatalf("loadMigrationFiles: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("loaded %d files, want 1", len(files))
+	}
+
+	up := storage.SplitStatements(files[0].UpSQL)
+	if len(up) != 2 {
+		t.Fatalf("UP split into %d statements, want 2:\n%s", len(up), strings.Join(up, "\n----\n"))
+	}
+	trigger := up[1]
+	if !strings.Contains(trigger, "CREATE TRIGGER users_audit") || !strings.Contains(trigger, "UPDATE users SET") || !strings.Contains(trigger, "END;") {
+		t.Errorf("trigger block was not kept whole:\n%s", trigger)
+	}
+	if down := storage.SplitStatements(files[0].DownSQL); len(down) != 2 {
+		t.Errorf("DOWN split into %d statements, want 2", len(down))
+	}
+}
@@ -0,0 +1,60 @@
+package migration
+
+import (
+	"context"
+	"sync"
+)
+
+// applyConcurrently applies a group of independent migrations using up to
+// workers goroutines until ctx is cancelled, recording each under runID as soon as it finishes. It returns the
+// number of migrations applied and the first error encountered; once a
+// migration fails or the run is cancelled no further migrations from the
+// group are started.
+func (e *Engine) applyConcurrently(ctx context.Context, files []MigrationFile, workers int, runID string) (int, error) {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		applied  int
+		firstErr error
+	)
+
+	jobs := make(chan MigrationFile)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range jobs {
+				err := e.applyMigration(ctx, file, runID)
+
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				} else if err == nil {
+					applied++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, file := range files {
+		mu.Lock()
+		failed := firstErr != nil
+		if !failed {
+			if err := ctx.Err(); err != nil {
+				firstErr = newError(CodeCancelled, file.Version, err, "cancelled before migration %s", file.Version)
+				failed = true
+			}
+		}
+		mu.Unlock()
+		if failed {
+			break
+		}
+		jobs <- file
+	}
+	close(jobs)
+	wg.Wait()
+
+	return applied, firstErr
+}
@@ -0,0 +1,62 @@
+package migration
+
+import (
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestUpConcurrency(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"001_a.sql":     "-- migrate:independent\n-- ==== UP ====\nCREATE TABLE a (id INTEGER);\n",
+		"002_b.sql":     "-- migrate:independent\n-- ==== UP ====\nCREATE TABLE b (id INTEGER);\n",
+		"003_c.sql":     "-- migrate:independent\n-- ==== UP ====\nCREATE TABLE c (id INTEGER);\n",
+		"004_both.sql":  "-- ==== UP ====\nCREATE TABLE both_ab AS SELECT a.id FROM a JOIN b ON a.id = b.id;\n",
+		"005_d.sql":     "-- migrate:independent\n-- ==== UP ====\nCREATE TABLE d (id INTEGER);\n",
+		"006_after.sql": "-- ==== UP ====\nCREATE TABLE after_d AS SELECT id FROM d;\n",
+	})
+
+	s := openTempStorage(t)
+	e := NewEngine(s, dir)
+	e.SetOutput(io.Discard)
+
+	var mu sync.Mutex
+	var tables []string
+	s.SetStatementLogger(func(stmt string) {
+		if name, ok := strings.CutPrefix(stmt, "CREATE TABLE "); ok {
+			mu.Lock()
+			tables = append(tables, strings.Fields(name)[0])
+			mu.Unlock()
+		}
+	})
+
+	if err := e.Up(UpOptions{Concurrency: 4}); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	versions, err := s.GetAppliedVersions(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"001", "002", "003", "004", "005", "006"}; !slices.Equal(versions, want) {
+		t.Errorf("applied versions = %v, want %v", versions, want)
+	}
+
+	// The independent group may run in any order, but each non-independent
+	// migration waits for every migration before it
+	pos := func(table string) int { return slices.Index(tables, table) }
+	if len(tables) != 6 {
+		t.Fatalf("created tables %v, want 6", tables)
+	}
+	for _, before := range []string{"a", "b", "c"} {
+		if pos(before) > pos("both_ab") {
+			t.Errorf("created tables %v: %s after both_ab", tables, before)
+		}
+	}
+	if !(pos("both_ab") < pos("d") && pos("d") < pos("after_d")) {
+		t.Errorf("created tables %v, want both_ab, d, after_d in order", tables)
+	}
+}
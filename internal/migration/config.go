@@ -0,0 +1,60 @@
+package migration
+
+import (
+	"github.com/rubenmeza/turso-migrate/internal/storage"
+	"github.com/rubenmeza/turso-migrate/pkg/config"
+)
+
+// OpenStorage connects to the database described by cfg. With readOnly, or
+// in read-only mode, the tracking table is neither created nor altered, so
+// read-only credentials can be used. An empty Dialect means SQLite, and
+// Flyway compatibility mode keeps the history in Flyway's format.
+func OpenStorage(cfg *config.Config, readOnly bool) (*storage.TursoStorage, error) {
+	var dialect storage.Dialect = storage.SQLiteDialect{}
+	if cfg.Compat == CompatFlyway {
+		dialect = storage.FlywayDialect{}
+	} else if cfg.Dialect != "" {
+		var err error
+		if dialect, err = storage.DialectByName(cfg.Dialect); err != nil {
+			return nil, err
+		}
+	}
+	return storage.NewWithOptions(cfg.DatabaseURL, cfg.AuthToken, storage.Options{
+		Table:             cfg.Table,
+		Namespace:         cfg.Namespace,
+		Dialect:           dialect,
+		TrackingDB:        cfg.TrackingDB,
+		LockTable:         cfg.LockTable,
+		LockTimeout:       cfg.LockTimeout,
+		LockRetryInterval: cfg.LockRetryInterval,
+		LockStaleAfter:    cfg.LockStaleAfter,
+		ExecRetries:       cfg.ExecRetries,
+		ExecRetryDelay:    cfg.ExecRetryDelay,
+		SkipInitSchema:    readOnly || cfg.ReadOnly,
+	})
+}
+
+// Configure applies the engine settings in cfg: the size limit, read-only
+// mode, statement logging, migration timeout, skipping invalid files,
+// environment expansion, version format, compatibility mode and filename
+// pattern. Output settings such as color and quiet are left to the caller.
+func (e *Engine) Configure(cfg *config.Config) error {
+	e.SetMaxMigrationSize(cfg.MaxMigrationSize)
+	e.SetReadOnly(cfg.ReadOnly)
+	e.SetVerbose(cfg.Verbose)
+	e.SetTimeout(cfg.Timeout)
+	e.SetSkipInvalid(cfg.SkipInvalid)
+	e.SetExpandEnv(cfg.ExpandEnv)
+	if err := e.SetVersionFormat(cfg.VersionFormat); err != nil {
+		return err
+	}
+	if err := e.SetCompat(cfg.Compat); err != nil {
+		return err
+	}
+	if cfg.FilenamePattern != "" {
+		if err := e.SetFilenamePattern(cfg.FilenamePattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package migration
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Confirm asks the question in prompt on out and reads the answer from r.
+// With an empty phrase the answer must be "y"; otherwise the exact phrase
+// must be typed, which makes dangerous operations harder to approve by
+// accident. It returns false without error when the answer doesn't match.
+func Confirm(r *bufio.Reader, out io.Writer, prompt, phrase string) (bool, error) {
+	if phrase == "" {
+		fmt.Fprintf(out, "%s [y/N] ", prompt)
+	} else {
+		fmt.Fprintf(out, "%s\nType %q to continue: ", prompt, phrase)
+	}
+
+	answer, err := r.ReadString('\n')
+	if err != nil && answer == "" {
+		if err == io.EOF {
+			fmt.Fprintln(out)
+			return false, nil
+		}
+		return false, err
+	}
+	answer = strings.TrimSpace(answer)
+
+	if phrase == "" {
+		return strings.ToLower(answer) == "y", nil
+	}
+	return answer == phrase, nil
+}
+
+// confirmPlan lists items under heading and asks to continue, reading the
+// answer from input. A declined answer fails with an error saying nothing
+// was done.
+func (e *Engine) confirmPlan(input io.Reader, phrase, heading string, items []string, declined string) error {
+	prompt := heading + "\n  " + strings.Join(items, "\n  ") + "\nContinue?"
+	ok, err := Confirm(bufio.NewReader(input), e.prompt, prompt, phrase)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%s", declined)
+	}
+	return nil
+}
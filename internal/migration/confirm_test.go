@@ -0,0 +1,26 @@
+package migration
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestConfirmPlanPromptsWhenQuiet(t *testing.T) {
+	e := NewEngine(nil, t.TempDir())
+	e.SetOutput(io.Discard)
+	var prompt bytes.Buffer
+	e.SetPromptOutput(&prompt)
+
+	if err := e.confirmPlan(strings.NewReader("y\n"), "", "Migrations to roll back:", []string{"002_users"}, "cancelled"); err != nil {
+		t.Fatalf("confirmPlan: %v", err)
+	}
+	if !strings.Contains(prompt.String(), "002_users") || !strings.Contains(prompt.String(), "Continue?") {
+		t.Errorf("prompt output = %q, want the plan and the question", prompt.String())
+	}
+
+	if err := e.confirmPlan(strings.NewReader("n\n"), "", "Migrations to roll back:", []string{"002_users"}, "cancelled"); err == nil {
+		t.Error("confirmPlan accepted a declined answer")
+	}
+}
@@ -0,0 +1,65 @@
+package migration
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCreateSkipsTakenVersion(t *testing.T) {
+	dir := t.TempDir()
+
+	// Take the version a timestamp create would compute now and the few
+	// after it, as another create running at the same time would
+	now, err := strconv.Atoi(time.Now().UTC().Format(timestampVersionLayout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	taken := make(map[string]string)
+	for i := 0; i < 3; i++ {
+		name := fmt.Sprintf("%d_add_users.sql", now+i)
+		taken[name] = fmt.Sprintf("-- ==== UP ====\nSELECT %d;\n", i)
+	}
+	writeFiles(t, dir, taken)
+
+	e := NewEngine(nil, dir)
+	e.SetOutput(io.Discard)
+	if err := e.SetVersionFormat(VersionTimestamp); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Create("add_users", CreateOptions{}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(taken)+1 {
+		t.Errorf("got %d files, want the %d existing ones and a new one", len(entries), len(taken))
+	}
+	for name, content := range taken {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(got) != content {
+			t.Errorf("%s was overwritten: %q, %v", name, got, err)
+		}
+	}
+}
+
+func TestCreateInMissingDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "migrations")
+
+	e := NewEngine(nil, dir)
+	e.SetOutput(io.Discard)
+	if err := e.Create("create_users", CreateOptions{}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "001_create_users.sql")); err != nil {
+		t.Errorf("first migration not created as version 001: %v", err)
+	}
+}
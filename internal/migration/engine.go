@@ -2,14 +2,22 @@ package migration
 
 import (
 	"bufio"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
+	pathpkg "path"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/rubenmeza/turso-migrate/internal/storage"
@@ -17,29 +25,377 @@ import (
 
 // MigrationFile represents a migration file on disk
 type MigrationFile struct {
+	Version     string
+	Name        string
+	Description string
+	// Note is the "-- Note:" header, recorded with the migration when it
+	// is applied
+	Note        string
+	Created     time.Time
+	Path        string
+	UpSQL       string
+	DownSQL     string
+	Manual      bool
+	Independent bool
+	// Irreversible is set by a "-- migrate:irreversible" line and marks a
+	// migration that deliberately has no DOWN section
+	Irreversible bool
+	// UpNoTxn and DownNoTxn are set by a "-- txn:none" line at the top of
+	// the UP or DOWN section, which then runs outside any transaction
+	UpNoTxn   bool
+	DownNoTxn bool
+
+	// markers are the section markers found in the file, or nil if the
+	// whole file is the UP section
+	markers *sectionMarkers
+	// fsName is the file's name in the engine's file system
+	fsName string
+}
+
+// CreateOptions controls how new migration files are created
+type CreateOptions struct {
+	// DryRun prints the path and content instead of writing the file
+	DryRun bool
+	// NoDown omits the DOWN section and marks the migration irreversible
+	NoDown bool
+	// SQLOnly omits the section markers, making the whole file the UP
+	// section
+	SQLOnly bool
+	// Template is the path of a text/template file rendered as the header
+	// comment block instead of the default one, or a built-in template
+	// such as "create-table:users" that fills in the UP and DOWN SQL
+	Template string
+	// Empty leaves out the header comment block, so the file holds only
+	// the section markers
+	Empty bool
+	// Note is written as a "-- Note:" header line and recorded with the
+	// migration when it is applied
+	Note string
+	// Dir is a subdirectory of the migrations directory to create the
+	// file in; versions stay unique across all subdirectories
+	Dir string
+}
+
+// UpOptions controls how pending migrations are applied
+type UpOptions struct {
+	// Approve is the version of a manual migration approved to run
+	Approve string
+	// Shard and Shards restrict the run to migrations whose version hashes
+	// into shard Shard of Shards; zero Shards applies every migration
+	Shard  int
+	Shards int
+	// Concurrency is the number of independent migrations applied in
+	// parallel; values below 2 apply everything serially. Local databases
+	// are limited to one connection, so they always apply serially.
+	Concurrency int
+	// AssertSchema is a file of expected schema statements compared with
+	// the live schema after applying
+	AssertSchema string
+	// AfterDate, if set, skips pending migrations created at or before it,
+	// judged by their "-- Created:" header or timestamp version
+	AfterDate time.Time
+	// GitRef, if set, applies the migration files as they were at this git
+	// commit instead of those in the working tree
+	GitRef string
+	// ApplyTimeout is the time budget for the whole run; once it passes the
+	// run stops before the next migration. Zero means no limit.
+	ApplyTimeout time.Duration
+	// JSON prints a JSON summary of the run instead of the usual output
+	JSON bool
+	// DryRun prints the UP SQL of each migration that would be applied
+	// without executing or recording anything
+	DryRun bool
+	// StrictChecksums fails the run, instead of warning, when an applied
+	// migration's UP SQL changed since it was applied
+	StrictChecksums bool
+	// Atomic applies every pending migration in a single transaction, so
+	// a failure leaves none of them applied
+	Atomic bool
+	// Strict refuses to run when a pending migration is older than the
+	// newest applied one instead of applying it out of order
+	Strict bool
+	// Limit caps how many pending migrations are applied, in order; zero
+	// applies them all
+	Limit int
+	// Check compiles every statement of the pending migrations with
+	// EXPLAIN before applying any, failing the run on a syntax error
+	Check bool
+	// Only applies just the pending migration with this version, leaving
+	// every other one pending
+	Only string
+	// Force lets Only apply a migration out of order, ahead of older
+	// pending migrations or after a newer applied one; Strict still
+	// refuses it
+	Force bool
+	// Input, if set, is where the answer is read from when asking to
+	// confirm the migration applied with Only; nil applies it without
+	// asking
+	Input io.Reader
+	// Phrase, if set, must be typed to confirm instead of "y"
+	Phrase string
+	// BeforeHook and AfterHook are SQL run outside any transaction before
+	// the first and after the last pending migration. A failed before hook
+	// stops the run; the after hook also runs when a migration fails.
+	BeforeHook string
+	AfterHook  string
+}
+
+// StatusOptions controls what Status prints
+type StatusOptions struct {
+	// PreviewLatest prints the UP SQL of the latest pending migration
+	PreviewLatest bool
+	// JSON prints a JSON array describing each migration instead
+	JSON bool
+	// Durations shows how long each applied migration took to execute
+	Durations bool
+	// Summary prints only a one-line count of applied and pending
+	// migrations and the current version
+	Summary bool
+}
+
+// DownOptions controls how applied migrations are rolled back
+type DownOptions struct {
+	// FromFile supplies the DOWN SQL for a migration whose file is missing
+	FromFile string
+	// Version, if set, must match the last applied migration
 	Version string
-	Name    string
-	Path    string
-	UpSQL   string
-	DownSQL string
+	// Steps is the number of most recently applied migrations to roll
+	// back, newest first; values below 1 roll back one
+	Steps int
+	// Soft marks the record as rolled back instead of deleting it
+	Soft bool
+	// LastRun rolls back every migration applied by the most recent up run
+	LastRun bool
+	// To rolls back, newest first, every migration applied after this
+	// applied version
+	To string
+	// DryRun prints the DOWN SQL that would run without executing it
+	DryRun bool
+	// SkipMissingDown removes the records of migrations without a DOWN
+	// section instead of aborting, leaving their schema changes in place
+	SkipMissingDown bool
+	// Input, if set, is where the answer is read from when asking to
+	// confirm the migrations to roll back; nil rolls back without asking
+	Input io.Reader
+	// Phrase, if set, must be typed to confirm instead of "y"
+	Phrase string
 }
 
+// DefaultMaxMigrationSize is the default limit on the size of a migration file
+const DefaultMaxMigrationSize = 16 << 20
+
+// DefaultVersionWidth is the default zero-padding width of generated versions
+const DefaultVersionWidth = 3
+
+// Version formats accepted by SetVersionFormat
+const (
+	VersionSequential = "sequential"
+	VersionTimestamp  = "timestamp"
+)
+
+// timestampVersionLayout is the layout of timestamp versions
+const timestampVersionLayout = "20060102150405"
+
+// DefaultFilenamePattern matches migration filenames like 001_create_users.sql
+const DefaultFilenamePattern = `^(?P<version>\d+)_(?P<name>.+)\.sql$`
+
 // Engine handles Turso database migration operations
 type Engine struct {
-	storage       *storage.TursoStorage
-	migrationsDir string
+	storage          *storage.TursoStorage
+	migrationsDir    string
+	fsys             fs.FS
+	root             string
+	onDisk           bool
+	maxMigrationSize int64
+	streaming        bool
+	filenamePattern  *regexp.Regexp
+	filenameFormat   string
+	ignorePattern    *regexp.Regexp
+	skipInvalid      bool
+	expandEnv        bool
+	upOnly           bool
+	markers          sectionMarkers
+	color            bool
+	readOnly         bool
+	out              io.Writer
+	prompt           io.Writer
+	warn             io.Writer
+	versionWidth     int
+	timestamps       bool
+	timeout          time.Duration
+
+	// report collects the migrations applied during an UpApplied run
+	report *runLog
+	// tx is the transaction of an up --atomic run
+	tx *sql.Tx
 }
 
-// NewEngine creates a new Turso migration engine
+// NewEngine creates a new Turso migration engine reading migrations from
+// the directory migrationsDir
 func NewEngine(storage *storage.TursoStorage, migrationsDir string) *Engine {
+	e := NewEngineFS(storage, os.DirFS(migrationsDir), ".")
+	e.migrationsDir = migrationsDir
+	e.onDisk = true
+	return e
+}
+
+// NewEngineFS creates a new Turso migration engine reading migrations from
+// the directory root of fsys, such as an embed.FS compiled into the binary.
+// Migrations can't be created in such an engine.
+func NewEngineFS(storage *storage.TursoStorage, fsys fs.FS, root string) *Engine {
 	return &Engine{
-		storage:       storage,
-		migrationsDir: migrationsDir,
+		storage:          storage,
+		migrationsDir:    root,
+		fsys:             fsys,
+		root:             root,
+		maxMigrationSize: DefaultMaxMigrationSize,
+		filenamePattern:  regexp.MustCompile(DefaultFilenamePattern),
+		filenameFormat:   "%s_%s.sql",
+		markers:          defaultMarkers,
+		out:              os.Stdout,
+		prompt:           os.Stderr,
+		warn:             os.Stderr,
+		versionWidth:     DefaultVersionWidth,
+	}
+}
+
+// SetVersionFormat sets how Create numbers new migrations: sequential
+// versions like 004, or timestamp versions like 20240115093000 that don't
+// conflict when migrations are created on different branches
+func (e *Engine) SetVersionFormat(format string) error {
+	switch format {
+	case VersionSequential, "":
+		e.timestamps = false
+	case VersionTimestamp:
+		e.timestamps = true
+	default:
+		return fmt.Errorf("invalid version format %q: must be sequential or timestamp", format)
+	}
+	return nil
+}
+
+// SetVersionWidth sets the zero-padding width of generated versions
+func (e *Engine) SetVersionWidth(width int) {
+	e.versionWidth = width
+}
+
+// SetTimeout limits how long the UP or DOWN SQL of a single migration may
+// run before it is interrupted and fails; zero means no limit
+func (e *Engine) SetTimeout(timeout time.Duration) {
+	e.timeout = timeout
+}
+
+// SetOutput sets the writer for informational output, which defaults to
+// os.Stdout; use io.Discard to silence it. The writer must be safe for
+// concurrent use when applying migrations with UpOptions.Concurrency.
+func (e *Engine) SetOutput(w io.Writer) {
+	e.out = w
+}
+
+// SetPromptOutput sets the writer for confirmation prompts, which defaults
+// to os.Stderr. It is separate from SetOutput so that silencing output
+// never hides a question waiting for an answer.
+func (e *Engine) SetPromptOutput(w io.Writer) {
+	e.prompt = w
+}
+
+// SetWarningOutput sets the writer for warnings about migration files left
+// out of a run, which defaults to os.Stderr so that they are neither lost
+// with --quiet nor mixed into output meant for other programs
+func (e *Engine) SetWarningOutput(w io.Writer) {
+	e.warn = w
+}
+
+// SetVerbose makes the engine print each SQL statement of a migration, to
+// the output set by SetOutput, before executing it
+func (e *Engine) SetVerbose(verbose bool) {
+	if e.storage == nil {
+		return
+	}
+	if !verbose {
+		e.storage.SetStatementLogger(nil)
+		return
+	}
+	e.storage.SetStatementLogger(func(stmt string) {
+		fmt.Fprintf(e.out, "  %s\n", strings.ReplaceAll(stmt, "\n", "\n  "))
+	})
+}
+
+// SetFilenamePattern sets the regular expression migration filenames must
+// match; it must contain named capture groups "version" and "name"
+func (e *Engine) SetFilenamePattern(pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid filename pattern: %w", err)
+	}
+	if re.SubexpIndex("version") < 0 || re.SubexpIndex("name") < 0 {
+		return fmt.Errorf("filename pattern %q must contain (?P<version>...) and (?P<name>...) groups", pattern)
+	}
+
+	e.filenamePattern = re
+	return nil
+}
+
+// SetMaxMigrationSize sets the maximum migration file size in bytes;
+// zero or less disables the limit
+func (e *Engine) SetMaxMigrationSize(size int64) {
+	e.maxMigrationSize = size
+}
+
+// SetSkipInvalid makes the engine skip migration files that fail to parse,
+// with a warning, instead of failing. Validate still reports them.
+func (e *Engine) SetSkipInvalid(skip bool) {
+	e.skipInvalid = skip
+}
+
+// SetReadOnly makes every operation that writes to the database or the
+// migrations directory fail with a READ_ONLY error
+func (e *Engine) SetReadOnly(readOnly bool) {
+	e.readOnly = readOnly
+}
+
+// checkWritable returns a READ_ONLY error describing action in read-only mode
+func (e *Engine) checkWritable(action string) error {
+	if e.readOnly {
+		return newError(CodeReadOnly, "", nil, "cannot %s in read-only mode", action)
 	}
+	return nil
+}
+
+// SetStreaming enables executing UP sections statement by statement
+// straight from the file instead of loading them into memory. In streaming
+// mode MigrationFile.UpSQL is left empty and the size limit is not applied.
+func (e *Engine) SetStreaming(streaming bool) {
+	e.streaming = streaming
 }
 
 // Create creates a new migration file for Turso
-func (e *Engine) Create(name string) error {
+func (e *Engine) Create(name string, opts CreateOptions) error {
+	content, err := e.renderTemplate(name, opts)
+	if err != nil {
+		return err
+	}
+	return e.writeMigration(name, content, opts.Dir, opts.DryRun)
+}
+
+// writeMigration writes content to a new migration file named after the
+// next version and name in the subdirectory dir of the migrations
+// directory, or prints it if dryRun
+func (e *Engine) writeMigration(name, content, dir string, dryRun bool) error {
+	if dir != "" && !filepath.IsLocal(dir) {
+		return fmt.Errorf("invalid directory %q: it must be a relative path inside the migrations directory", dir)
+	}
+	dir = filepath.Clean(dir)
+
+	if !dryRun {
+		if err := e.checkWritable("create migrations"); err != nil {
+			return err
+		}
+		if !e.onDisk {
+			return fmt.Errorf("cannot create migrations in a read-only file system; create them in the source directory")
+		}
+	}
+
 	// Get next version number
 	version, err := e.getNextVersion()
 	if err != nil {
@@ -48,35 +404,241 @@ func (e *Engine) Create(name string) error {
 
 	// Sanitize name
 	sanitizedName := sanitizeName(name)
-	filename := fmt.Sprintf("%s_%s.sql", version, sanitizedName)
-	filepath := filepath.Join(e.migrationsDir, filename)
+
+	if dryRun {
+		filename, err := e.migrationFilename(version, sanitizedName)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(e.out, filepath.Join(e.migrationsDir, dir, filename))
+		fmt.Fprint(e.out, content)
+		return nil
+	}
 
 	// Ensure migrations directory exists
-	if err := os.MkdirAll(e.migrationsDir, 0755); err != nil {
+	if err := os.MkdirAll(filepath.Join(e.migrationsDir, dir), 0755); err != nil {
 		return fmt.Errorf("failed to create migrations directory: %w", err)
 	}
 
-	// Create migration file with template
-	template := fmt.Sprintf(`-- Migration: %s
--- Created: %s
+	// Another create running at the same time may have taken the version
+	// since it was computed, so move on to the next free one
+	for attempt := 1; ; attempt++ {
+		filename, err := e.migrationFilename(version, sanitizedName)
+		if err != nil {
+			return err
+		}
+
+		taken, err := e.versionTaken(version)
+		if err != nil {
+			return err
+		}
+		if !taken {
+			err = writeNewFile(filepath.Join(e.migrationsDir, dir, filename), content)
+			if err == nil {
+				fmt.Fprintf(e.out, "Created migration: %s\n", filepath.Join(dir, filename))
+				return nil
+			}
+			if !errors.Is(err, fs.ErrExist) {
+				return fmt.Errorf("failed to create migration file: %w", err)
+			}
+		}
 
--- ==== UP ====
+		if attempt == maxCreateAttempts {
+			return fmt.Errorf("failed to create migration file: version %s and the %d before it are already taken", version, maxCreateAttempts-1)
+		}
+		if version, err = e.bumpVersion(version); err != nil {
+			return err
+		}
+	}
+}
 
+// maxCreateAttempts is how many versions writeMigration tries before
+// giving up on finding a free one
+const maxCreateAttempts = 10
 
--- ==== DOWN ====
+// migrationFilename returns the filename of a new migration, checking that
+// the loader will recognize it
+func (e *Engine) migrationFilename(version, name string) (string, error) {
+	filename := fmt.Sprintf(e.filenameFormat, version, name)
+	if !e.filenamePattern.MatchString(filename) {
+		return "", fmt.Errorf("generated filename %s does not match the filename pattern %s", filename, e.filenamePattern)
+	}
+	return filename, nil
+}
 
-`, name, time.Now().Format("2006-01-02 15:04:05"))
+// versionTaken reports whether a migration file with version already
+// exists in the migrations directory, whatever its name
+func (e *Engine) versionTaken(version string) (bool, error) {
+	paths, err := e.migrationPaths()
+	if err != nil {
+		return false, err
+	}
+	for _, path := range paths {
+		matches := e.filenamePattern.FindStringSubmatch(pathpkg.Base(path))
+		if matches != nil && matches[e.filenamePattern.SubexpIndex("version")] == version {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// writeNewFile writes content to path, failing with fs.ErrExist rather
+// than overwriting an existing file
+func writeNewFile(path, content string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
 
-	if err := os.WriteFile(filepath, []byte(template), 0644); err != nil {
-		return fmt.Errorf("failed to create migration file: %w", err)
+// renderTemplate returns the initial content of a new migration file
+func (e *Engine) renderTemplate(name string, opts CreateOptions) (string, error) {
+	if opts.NoDown && opts.SQLOnly {
+		return "", fmt.Errorf("--no-down and --sql-only cannot be combined")
 	}
 
-	fmt.Printf("Created migration: %s\n", filename)
-	return nil
+	headerFile := opts.Template
+	upSQL, downSQL, builtin, err := renderBuiltin(opts.Template)
+	if err != nil {
+		return "", err
+	}
+	if builtin {
+		headerFile = ""
+	}
+	if opts.Empty && headerFile != "" {
+		return "", fmt.Errorf("--empty and --template cannot be combined")
+	}
+	if opts.Empty && opts.Note != "" {
+		return "", fmt.Errorf("--empty and --note cannot be combined; the note is part of the header")
+	}
+	if strings.ContainsAny(opts.Note, "\r\n") {
+		return "", fmt.Errorf("--note must be a single line")
+	}
+
+	var header string
+	if !opts.Empty {
+		rendered, err := e.renderHeader(name, headerFile, opts.Note)
+		if err != nil {
+			return "", err
+		}
+		header = rendered + "\n"
+	}
+
+	switch {
+	case e.upOnly || opts.SQLOnly:
+		if upSQL == "" {
+			return header, nil
+		}
+		return header + upSQL + "\n", nil
+	case opts.NoDown:
+		return fmt.Sprintf("%s-- migrate:irreversible\n\n-- %s\n%s\n", header, e.markers.up, upSQL), nil
+	}
+
+	return fmt.Sprintf("%s-- %s\n%s\n\n-- %s\n%s\n", header, e.markers.up, upSQL, e.markers.down, downSQL), nil
+}
+
+// headerData is what a --template header can refer to
+type headerData struct {
+	Name    string
+	Created string
+	Note    string
+}
+
+// renderHeader returns the header comment block of a new migration,
+// rendered from the template file at path if set, with a "-- Note:" line
+// for note unless the template wrote one. A header containing a section
+// marker is rejected since the file would no longer parse.
+func (e *Engine) renderHeader(name, path, note string) (string, error) {
+	data := headerData{Name: name, Created: time.Now().Format(createdLayout), Note: note}
+	header := fmt.Sprintf("-- Migration: %s\n-- Created: %s\n", data.Name, data.Created)
+	if path != "" {
+		rendered, err := e.renderHeaderFile(path, data)
+		if err != nil {
+			return "", err
+		}
+		header = rendered
+	}
+	if note != "" && parseHeader(strings.NewReader(header)).note == "" {
+		header += "-- Note: " + note + "\n"
+	}
+	return header, nil
+}
+
+// renderHeaderFile renders the header template file at path with data
+func (e *Engine) renderHeaderFile(path string, data headerData) (string, error) {
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read template: %w", err)
+	}
+	tmpl, err := template.New(filepath.Base(path)).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+
+	header := strings.TrimRight(b.String(), "\n") + "\n"
+	for _, line := range strings.Split(header, "\n") {
+		if strings.Contains(line, e.markers.up) || strings.Contains(line, e.markers.down) {
+			return "", fmt.Errorf("template %s must not contain the section markers %q and %q; they are added by create", path, e.markers.up, e.markers.down)
+		}
+	}
+	return header, nil
+}
+
+// Up applies all pending migrations, stopping before any manual migration
+// that has not been approved
+func (e *Engine) Up(opts UpOptions) error {
+	return e.UpContext(context.Background(), opts)
 }
 
-// Up applies all pending migrations
-func (e *Engine) Up() error {
+// UpContext is Up with a context. Once ctx is cancelled no further
+// migrations are started and the running one is interrupted and rolled
+// back.
+func (e *Engine) UpContext(ctx context.Context, opts UpOptions) error {
+	if opts.JSON {
+		return e.upJSON(ctx, opts)
+	}
+
+	if !opts.DryRun {
+		if err := e.checkWritable("apply migrations"); err != nil {
+			return err
+		}
+	}
+
+	if opts.Atomic && (e.streaming || opts.Concurrency > 1) {
+		return fmt.Errorf("--atomic cannot be combined with --stream or --concurrency")
+	}
+
+	// Streamed UP sections are read straight from the file, unexpanded
+	if e.expandEnv && e.streaming {
+		return fmt.Errorf("--expand-env cannot be combined with --stream")
+	}
+
+	if opts.GitRef != "" {
+		if !e.onDisk {
+			return fmt.Errorf("--git-ref needs migrations in a directory on disk")
+		}
+		dir, err := exportGitRef(e.migrationsDir, opts.GitRef)
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+
+		fmt.Fprintf(e.out, "Using migration files from git ref %s\n", opts.GitRef)
+		workingDir, workingFS := e.migrationsDir, e.fsys
+		e.migrationsDir, e.fsys = dir, os.DirFS(dir)
+		defer func() { e.migrationsDir, e.fsys = workingDir, workingFS }()
+	}
+
 	// Get migration files
 	files, err := e.loadMigrationFiles()
 	if err != nil {
@@ -84,14 +646,23 @@ func (e *Engine) Up() error {
 	}
 
 	if len(files) == 0 {
-		fmt.Println("No migrations found")
+		fmt.Fprintln(e.out, "No migrations found")
 		return nil
 	}
 
+	// Sharded runs are meant to apply in parallel, so only lock whole runs,
+	// and a dry run only reads
+	if opts.Shards == 0 && !opts.DryRun {
+		if err := e.lock(ctx); err != nil {
+			return err
+		}
+		defer e.unlock()
+	}
+
 	// Get applied migrations
-	applied, err := e.storage.GetAppliedMigrations()
+	applied, err := e.storage.GetAppliedMigrationsContext(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get applied migrations: %w", err)
+		return newError(CodeStorageFailed, "", err, "failed to get applied migrations")
 	}
 
 	// Build set of applied versions
@@ -100,103 +671,757 @@ func (e *Engine) Up() error {
 		appliedSet[m.Version] = true
 	}
 
-	// Apply pending migrations
-	var appliedCount int
+	if opts.Approve != "" {
+		if err := checkApproval(files, appliedSet, opts.Approve); err != nil {
+			return err
+		}
+	}
+
+	modified, err := e.modifiedMigrations(files, applied)
+	if err != nil {
+		return err
+	}
+	if len(modified) > 0 && opts.StrictChecksums {
+		return newError(CodeChecksumMismatch, modified[0], nil, "applied migrations were modified: %s", strings.Join(modified, ", "))
+	}
+
+	// up --only checks the order of its one migration itself
+	late := outOfOrder(files, applied)
+	if len(late) > 0 && opts.Only == "" {
+		var versions []string
+		for _, file := range files {
+			if late[file.Version] {
+				versions = append(versions, file.Version)
+			}
+		}
+		if opts.Strict {
+			return newError(CodeOutOfOrder, versions[0], nil, "pending migrations are older than the newest applied one: %s", strings.Join(versions, ", "))
+		}
+		fmt.Fprintf(e.out, "Warning: applying migrations out of order, they are older than the newest applied one: %s\n", strings.Join(versions, ", "))
+	}
+
+	// Fail before applying anything rather than partway through the run
+	if !opts.DryRun && countPending(files, appliedSet) > 0 {
+		if err := e.storage.CheckAuth(); err != nil {
+			code := CodeStorageFailed
+			if errors.Is(err, storage.ErrAuth) {
+				code = CodeAuthFailed
+			}
+			return newError(code, "", err, "no migrations were applied")
+		}
+	}
+
+	// Collect pending migrations
+	var pending []MigrationFile
+	if opts.Only != "" {
+		file, err := e.onlyMigration(files, appliedSet, late, opts.Only, opts.Force, opts.Strict)
+		if err != nil {
+			return err
+		}
+		pending = append(pending, file)
+	}
 	for _, file := range files {
+		if opts.Only != "" {
+			break
+		}
+
 		if appliedSet[file.Version] {
 			continue // Skip already applied
 		}
 
-		fmt.Printf("Applying migration %s: %s\n", file.Version, file.Name)
+		if opts.Shards > 0 && ShardOf(file.Version, opts.Shards) != opts.Shard {
+			continue // Belongs to another shard
+		}
+
+		pending = append(pending, file)
+	}
+
+	if !opts.AfterDate.IsZero() {
+		pending = e.filterAfterDate(pending, opts.AfterDate)
+	}
+
+	var held int
+	if opts.Limit > 0 && len(pending) > opts.Limit {
+		held = len(pending) - opts.Limit
+		pending = pending[:opts.Limit]
+	}
+
+	if opts.Check {
+		if err := e.checkSyntax(ctx, pending); err != nil {
+			return err
+		}
+	}
+
+	if opts.DryRun {
+		return e.printApplyPlan(pending, opts.Approve)
+	}
+
+	if opts.Only != "" && opts.Input != nil && len(pending) > 0 {
+		items := []string{pending[0].Version + " " + pending[0].Name}
+		if err := e.confirmPlan(opts.Input, opts.Phrase, "Migration to apply on its own, leaving the others pending:", items, "up --only cancelled; nothing was applied"); err != nil {
+			return err
+		}
+	}
+
+	if opts.Atomic {
+		for _, file := range pending {
+			if file.UpNoTxn {
+				return newError(CodeInvalidMigration, file.Version, nil,
+					"migration %s (%s) runs outside a transaction with -- txn:none and can't be part of an atomic run", file.Version, file.Name)
+			}
+		}
+	}
+
+	// The after hook is cleared once it has run, so the deferred call only
+	// runs it when the run stops early
+	var afterHook string
+	if len(pending) > 0 && hasHooks(opts) {
+		// Hooks mostly change settings, such as PRAGMA foreign_keys, that
+		// only hold on the connection they ran on, so the hooks and every
+		// migration share one connection and migrations run one at a time
+		unpin, err := e.storage.PinConnContext(ctx)
+		if err != nil {
+			return newError(CodeStorageFailed, "", err, "failed to open a connection; no migrations were applied")
+		}
+		defer unpin()
+		opts.Concurrency = 1
 
-		// Execute UP SQL
-		if err := e.storage.ExecuteSQL(file.UpSQL); err != nil {
-			return fmt.Errorf("failed to execute migration %s: %w", file.Version, err)
+		if err := e.runHook(ctx, "before", opts.BeforeHook); err != nil {
+			return fmt.Errorf("%w; no migrations were applied", err)
+		}
+		afterHook = opts.AfterHook
+		defer func() {
+			// Still undo the before hook when the run was cancelled
+			if err := e.runHook(context.WithoutCancel(ctx), "after", afterHook); err != nil {
+				fmt.Fprintf(e.out, "Warning: %v\n", err)
+			}
+		}()
+	}
+
+	budget := ctx
+	if opts.ApplyTimeout > 0 {
+		var cancel context.CancelFunc
+		budget, cancel = context.WithTimeout(ctx, opts.ApplyTimeout)
+		defer cancel()
+	}
+
+	// Apply pending migrations
+	runID := newRunID()
+	var appliedCount, started int
+
+	if opts.Atomic && len(pending) > 0 {
+		tx, err := e.storage.BeginContext(ctx)
+		if err != nil {
+			return newError(CodeStorageFailed, "", err, "failed to begin transaction")
+		}
+		e.tx = tx
+		defer func() {
+			e.tx = nil
+			if tx.Rollback() == nil && started > 0 {
+				fmt.Fprintf(e.out, "Rolled back the transaction; none of the %d migration(s) run were kept\n", started)
+			}
+		}()
+	}
+
+	for i := 0; i < len(pending); i++ {
+		file := pending[i]
+
+		if err := e.cancelled(ctx, file.Version, appliedCount, len(pending)-i); err != nil {
+			return err
+		}
+
+		// Only stop between migrations, never in the middle of one
+		if budget.Err() != nil {
+			fmt.Fprintf(e.out, "Applied %d migration(s); apply timeout of %s reached, %d migration(s) still pending\n",
+				appliedCount, opts.ApplyTimeout, len(pending)-i)
+			return newError(CodeApplyTimeout, file.Version, budget.Err(), "apply timeout reached before migration %s", file.Version)
+		}
+
+		if file.Manual && file.Version != opts.Approve {
+			if appliedCount > 0 {
+				fmt.Fprintf(e.out, "Applied %d migration(s)\n", appliedCount)
+			}
+			fmt.Fprintf(e.out, "Migration %s (%s) requires manual approval\n", file.Version, file.Name)
+			fmt.Fprintf(e.out, "Review it, then run: turso-migrate up --approve %s\n", file.Version)
+			return newError(CodeApprovalRequired, file.Version, nil, "migration %s requires manual approval", file.Version)
+		}
+
+		if opts.Concurrency > 1 && file.Independent && !file.Manual {
+			// Run the consecutive group of independent migrations in parallel
+			end := i + 1
+			for end < len(pending) && pending[end].Independent && !pending[end].Manual {
+				end++
+			}
+
+			count, err := e.applyConcurrently(ctx, pending[i:end], opts.Concurrency, runID)
+			appliedCount += count
+			if err != nil {
+				return err
+			}
+
+			i = end - 1
+			continue
 		}
 
-		// Record migration
-		if err := e.storage.RecordMigration(file.Version, file.Name); err != nil {
-			return fmt.Errorf("failed to record migration %s: %w", file.Version, err)
+		started++
+		if err := e.applyMigration(ctx, file, runID); err != nil {
+			return err
 		}
 
 		appliedCount++
 	}
 
-	if appliedCount == 0 {
-		fmt.Println("No pending migrations")
-	} else {
-		fmt.Printf("Applied %d migration(s)\n", appliedCount)
+	if e.tx != nil {
+		if err := e.tx.Commit(); err != nil {
+			return newError(CodeMigrationFailed, "", err, "failed to commit migrations")
+		}
+	}
+
+	hook := afterHook
+	afterHook = ""
+	if err := e.runHook(ctx, "after", hook); err != nil {
+		return err
+	}
+
+	switch {
+	case appliedCount == 0:
+		fmt.Fprintln(e.out, "No pending migrations")
+	case held > 0:
+		fmt.Fprintf(e.out, "Applied %d migration(s); %d more pending beyond the limit of %d\n", appliedCount, held, opts.Limit)
+	default:
+		fmt.Fprintf(e.out, "Applied %d migration(s)\n", appliedCount)
+	}
+
+	if opts.AssertSchema != "" {
+		return e.AssertSchema(opts.AssertSchema)
+	}
+
+	return nil
+}
+
+// checkSyntax compiles the UP statements of each file with EXPLAIN without
+// executing them, stopping at the first migration with a syntax error
+func (e *Engine) checkSyntax(ctx context.Context, files []MigrationFile) error {
+	for _, file := range files {
+		upSQL, err := e.expandSQL(file.UpSQL)
+		if err != nil {
+			return newError(CodeInvalidMigration, file.Version, err, "migration %s (%s) failed the syntax check; nothing was applied", file.Version, file.Name)
+		}
+		r := io.NopCloser(strings.NewReader(upSQL))
+		if e.streaming {
+			if r, err = e.openUp(file); err != nil {
+				return fmt.Errorf("failed to read %s: %w", file.Path, err)
+			}
+		}
+
+		err = e.storage.CheckSyntaxContext(ctx, r)
+		r.Close()
+		if err != nil {
+			return newError(CodeInvalidMigration, file.Version, err, "migration %s (%s) failed the syntax check; nothing was applied", file.Version, file.Name)
+		}
+	}
+	fmt.Fprintf(e.out, "Syntax check passed for %d migration(s)\n", len(files))
+	return nil
+}
+
+// printApplyPlan prints the UP SQL of each pending migration in the order
+// it would run, stopping at a manual migration other than approve
+func (e *Engine) printApplyPlan(pending []MigrationFile, approve string) error {
+	for i, file := range pending {
+		if file.Manual && file.Version != approve {
+			fmt.Fprintf(e.out, "-- Migration %s (%s) requires manual approval; the run would stop here\n\n", file.Version, file.Name)
+			fmt.Fprintf(e.out, "Dry run: %d migration(s) would be applied\n", i)
+			return nil
+		}
+
+		fmt.Fprintf(e.out, "-- Apply %s: %s\n", file.Version, file.Name)
+		if e.streaming {
+			r, err := e.openUp(file)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(e.out, r)
+			r.Close()
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(e.out, "\n\n")
+			continue
+		}
+		upSQL, err := e.expandSQL(file.UpSQL)
+		if err != nil {
+			return newError(CodeMigrationFailed, file.Version, err, "failed to print migration %s", file.Version)
+		}
+		fmt.Fprintf(e.out, "%s\n\n", upSQL)
+	}
+
+	fmt.Fprintf(e.out, "Dry run: %d migration(s) would be applied\n", len(pending))
+	return nil
+}
+
+// filterAfterDate drops pending migrations created at or before date,
+// warning when this leaves older migrations to be applied out of order.
+// Migrations without a known creation time are kept.
+func (e *Engine) filterAfterDate(pending []MigrationFile, date time.Time) []MigrationFile {
+	var kept []MigrationFile
+	var skipped []string
+	var dated bool
+
+	for _, file := range pending {
+		created := createdAt(file)
+		if !created.IsZero() {
+			dated = true
+		}
+		if !created.IsZero() && !created.After(date) {
+			skipped = append(skipped, file.Version)
+			continue
+		}
+		kept = append(kept, file)
+	}
+
+	if !dated {
+		fmt.Fprintln(e.out, "Warning: no pending migration has a Created header or timestamp version; applying all")
+		return pending
+	}
+
+	if len(skipped) > 0 {
+		fmt.Fprintf(e.out, "Skipping %d pending migration(s) created before %s: %s\n",
+			len(skipped), date.Format(createdLayout), strings.Join(skipped, ", "))
+		if len(kept) > 0 && versionLess(skipped[0], kept[len(kept)-1].Version) {
+			fmt.Fprintln(e.out, "Warning: skipped migrations come before ones being applied; applying them later will be out of order")
+		}
+	}
+	return kept
+}
+
+// createdAt returns when a migration was created, from its "-- Created:"
+// header or a YYYYMMDDHHMMSS version in UTC, or the zero time if unknown
+func createdAt(file MigrationFile) time.Time {
+	if !file.Created.IsZero() {
+		return file.Created
+	}
+	if len(file.Version) == len(timestampVersionLayout) && isNumeric(file.Version) {
+		if t, err := time.Parse(timestampVersionLayout, file.Version); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// applyMigration executes a migration's UP SQL and records it as applied
+// by the run runID
+func (e *Engine) applyMigration(ctx context.Context, file MigrationFile, runID string) error {
+	fmt.Fprintf(e.out, "Applying migration %s: %s\n", file.Version, file.Name)
+
+	checksum, err := e.upChecksum(file)
+	if err != nil {
+		return newError(CodeMigrationFailed, file.Version, err, "failed to checksum migration %s", file.Version)
+	}
+
+	// Execute UP SQL
+	start := time.Now()
+	execCtx, cancel := e.execContext(ctx)
+	err = e.executeUp(execCtx, file)
+	cancel()
+	if err != nil {
+		if e.timedOut(ctx, execCtx) {
+			return newError(CodeMigrationFailed, file.Version, err, "migration %s exceeded the timeout of %s", file.Version, e.timeout)
+		}
+		return newError(CodeMigrationFailed, file.Version, err, "failed to execute migration %s", file.Version)
+	}
+	duration := time.Since(start)
+
+	// Record migration
+	record := e.storage.RecordMigrationContext
+	if e.tx != nil {
+		record = func(ctx context.Context, version, name, runID, checksum, note string, duration time.Duration) error {
+			return e.storage.RecordMigrationTxContext(ctx, e.tx, version, name, runID, checksum, note, duration)
+		}
+	}
+	if err := record(ctx, file.Version, file.Name, runID, checksum, file.Note, duration); err != nil {
+		return newError(CodeRecordFailed, file.Version, err, "failed to record migration %s", file.Version)
+	}
+
+	if e.report != nil {
+		e.report.add(file, duration)
+	}
+	return nil
+}
+
+// Down rolls back the last applied migration, or the last opts.Steps
+// migrations or most recent run
+func (e *Engine) Down(opts DownOptions) error {
+	return e.DownContext(context.Background(), opts)
+}
+
+// DownContext is Down with a context. Once ctx is cancelled no further
+// migrations are rolled back and the running rollback is interrupted.
+func (e *Engine) DownContext(ctx context.Context, opts DownOptions) error {
+	// A dry run only reads, so it doesn't need the lock
+	if !opts.DryRun {
+		if err := e.checkWritable("roll back migrations"); err != nil {
+			return err
+		}
+		if err := e.lock(ctx); err != nil {
+			return err
+		}
+		defer e.unlock()
+	}
+
+	// Only read the records that may be rolled back; --last-run has to
+	// search the whole history for the latest run
+	var applied []storage.Migration
+	var err error
+	switch {
+	case opts.LastRun:
+		applied, err = e.storage.GetAppliedMigrationsContext(ctx)
+	case opts.To != "":
+		applied, err = e.storage.GetAppliedMigrationsRangeContext(ctx, opts.To, "")
+	default:
+		applied, err = e.storage.GetLastAppliedContext(ctx, max(opts.Steps, 1))
+	}
+	if err != nil {
+		return newError(CodeStorageFailed, "", err, "failed to get applied migrations")
+	}
+
+	if len(applied) == 0 {
+		fmt.Fprintln(e.out, "No migrations to rollback")
+		return nil
+	}
+
+	// Find the migration files
+	files, err := e.loadMigrationFiles()
+	if err != nil {
+		return fmt.Errorf("failed to load migration files: %w", err)
+	}
+
+	// Work out which migrations to roll back, newest first
+	var plan []MigrationFile
+	var runID string
+	switch {
+	case opts.LastRun:
+		runID, plan, err = lastRunPlan(applied, files)
+		if err != nil {
+			return err
+		}
+	case opts.To != "":
+		plan, err = toPlan(applied, files, opts.To)
+		if err != nil {
+			return err
+		}
+		if len(plan) == 0 {
+			fmt.Fprintf(e.out, "No migrations applied after %s\n", opts.To)
+			return nil
+		}
+	default:
+		lastMigration := applied[len(applied)-1]
+		if opts.Version != "" && opts.Version != lastMigration.Version {
+			return fmt.Errorf("version %s is not the last applied migration (last applied: %s)", opts.Version, lastMigration.Version)
+		}
+
+		steps := max(opts.Steps, 1)
+		if steps > len(applied) {
+			return fmt.Errorf("cannot roll back %d migrations: only %d applied", steps, len(applied))
+		}
+
+		for i := len(applied) - 1; i >= len(applied)-steps; i-- {
+			migrationFile, err := findDownFile(files, applied[i], opts.FromFile)
+			if err != nil {
+				return err
+			}
+			plan = append(plan, *migrationFile)
+		}
+	}
+
+	if opts.DryRun {
+		return e.printRollbackPlan(plan, opts.SkipMissingDown)
+	}
+
+	// A run, or everything after a target, is rolled back as a whole, so
+	// check every DOWN section before executing any; --steps instead stops
+	// at the first one missing
+	for _, file := range plan {
+		if (opts.LastRun || opts.To != "") && file.DownSQL == "" && !opts.SkipMissingDown {
+			return newError(CodeMissingDown, file.Version, nil, "%s", missingDown(file))
+		}
+	}
+
+	if opts.Input != nil {
+		var items []string
+		for _, file := range plan {
+			item := file.Version + " " + file.Name
+			if file.DownSQL == "" {
+				item += " (no DOWN section)"
+			}
+			items = append(items, item)
+		}
+		if err := e.confirmPlan(opts.Input, opts.Phrase, "Migrations to roll back, newest first:", items, "rollback cancelled; nothing was rolled back"); err != nil {
+			return err
+		}
+	}
+
+	if runID != "" {
+		fmt.Fprintf(e.out, "Rolling back run %s (%d migration(s))\n", runID, len(plan))
+	}
+
+	var versions, skipped []string
+	for i, file := range plan {
+		if err := e.cancelled(ctx, file.Version, i, len(plan)-i); err != nil {
+			return err
+		}
+
+		if file.DownSQL == "" {
+			fmt.Fprintf(e.out, "%s migration %s (%s) has no DOWN section; removing its record WITHOUT running any SQL\n",
+				e.paint(colorRed, "WARNING:"), file.Version, file.Name)
+			if err := e.removeRecord(ctx, file.Version, opts.Soft); err != nil {
+				return err
+			}
+			skipped = append(skipped, file.Version)
+			continue
+		}
+
+		if err := e.rollback(ctx, file, opts.Soft); err != nil {
+			if len(versions) > 0 {
+				fmt.Fprintf(e.out, "Rolled back %s before the failure\n", strings.Join(versions, ", "))
+			}
+			return err
+		}
+		versions = append(versions, file.Version)
+	}
+
+	if len(skipped) > 0 {
+		fmt.Fprintf(e.out, "%s records removed without executing SQL, their schema changes remain: %s\n",
+			e.paint(colorRed, "WARNING:"), strings.Join(skipped, ", "))
+	}
+
+	switch {
+	case runID != "":
+		fmt.Fprintf(e.out, "Rolled back run %s: %s\n", runID, strings.Join(versions, ", "))
+	case opts.To != "":
+		fmt.Fprintf(e.out, "Rolled back to %s: %s\n", opts.To, strings.Join(versions, ", "))
+	case len(plan) > 1:
+		fmt.Fprintf(e.out, "Rolled back %d migration(s): %s\n", len(versions), strings.Join(versions, ", "))
+	case len(versions) > 0:
+		fmt.Fprintln(e.out, "Migration rolled back successfully")
+	}
+	return nil
+}
+
+// toPlan returns the files of every migration applied after target, newest
+// first. The target must itself be applied.
+func toPlan(applied []storage.Migration, files []MigrationFile, target string) ([]MigrationFile, error) {
+	found := false
+	for _, m := range applied {
+		found = found || m.Version == target
+	}
+	if !found {
+		return nil, fmt.Errorf("target version %s is not applied", target)
+	}
+
+	// Applied migrations are ordered by version, so walk them backwards
+	var plan []MigrationFile
+	for i := len(applied) - 1; i >= 0 && versionLess(target, applied[i].Version); i-- {
+		file, err := findDownFile(files, applied[i], "")
+		if err != nil {
+			return nil, err
+		}
+		plan = append(plan, *file)
+	}
+	return plan, nil
+}
+
+// lastRunPlan returns the run that applied the most recent migration and
+// the files of every migration it applied, newest first
+func lastRunPlan(applied []storage.Migration, files []MigrationFile) (string, []MigrationFile, error) {
+	latest := applied[0]
+	for _, m := range applied[1:] {
+		if !m.AppliedAt.Before(latest.AppliedAt) {
+			latest = m
+		}
+	}
+	if latest.RunID == "" {
+		return "", nil, fmt.Errorf("migration %s was applied before run IDs were tracked; use down instead", latest.Version)
+	}
+
+	var batch []MigrationFile
+	for i := len(applied) - 1; i >= 0; i-- {
+		if applied[i].RunID != latest.RunID {
+			continue
+		}
+		file, err := findDownFile(files, applied[i], "")
+		if err != nil {
+			return "", nil, err
+		}
+		batch = append(batch, *file)
+	}
+	return latest.RunID, batch, nil
+}
+
+// printRollbackPlan prints the DOWN SQL of each migration in plan in the
+// order it would run, failing if any has no DOWN section unless skipMissing
+func (e *Engine) printRollbackPlan(plan []MigrationFile, skipMissing bool) error {
+	var missing []string
+	for _, file := range plan {
+		fmt.Fprintf(e.out, "-- Rollback %s: %s\n", file.Version, file.Name)
+		if file.DownSQL == "" {
+			if skipMissing {
+				fmt.Fprintf(e.out, "%s\n\n", e.paint(colorRed, "-- ✗ no DOWN section, record would be removed without running SQL"))
+				continue
+			}
+			fmt.Fprintf(e.out, "%s\n\n", e.paint(colorRed, "-- ✗ "+missingDown(file)))
+			missing = append(missing, file.Version)
+			continue
+		}
+		downSQL, err := e.expandSQL(file.DownSQL)
+		if err != nil {
+			return newError(CodeRollbackFailed, file.Version, err, "failed to print the rollback of %s", file.Version)
+		}
+		fmt.Fprintf(e.out, "%s\n\n", downSQL)
+	}
+
+	if len(missing) > 0 {
+		return newError(CodeMissingDown, missing[0], nil, "rollback is incomplete: no DOWN section in %s", strings.Join(missing, ", "))
+	}
+
+	fmt.Fprintf(e.out, "Dry run: %d migration(s) would be rolled back\n", len(plan))
+	return nil
+}
+
+// findDownFile returns the migration file for the applied migration m, or
+// one built from the rollback script fromFile if the file is missing
+func findDownFile(files []MigrationFile, m storage.Migration, fromFile string) (*MigrationFile, error) {
+	var migrationFile *MigrationFile
+	for _, file := range files {
+		if file.Version == m.Version {
+			migrationFile = &file
+			break
+		}
+	}
+
+	if fromFile != "" {
+		if migrationFile != nil {
+			return nil, fmt.Errorf("migration file for version %s exists at %s; --from-file is only for missing files", m.Version, migrationFile.Path)
+		}
+
+		downSQL, err := readDownScript(fromFile)
+		if err != nil {
+			return nil, err
+		}
+
+		migrationFile = &MigrationFile{
+			Version:   m.Version,
+			Name:      m.Name,
+			Path:      fromFile,
+			DownSQL:   downSQL,
+			DownNoTxn: leadingDirective(strings.NewReader(downSQL), "txn:none"),
+		}
 	}
 
-	return nil
-}
-
-// Down rolls back the last applied migration
-func (e *Engine) Down() error {
-	// Get applied migrations
-	applied, err := e.storage.GetAppliedMigrations()
-	if err != nil {
-		return fmt.Errorf("failed to get applied migrations: %w", err)
+	if migrationFile == nil {
+		return nil, newError(CodeFileNotFound, m.Version, nil, "migration file not found for version %s", m.Version)
 	}
+	return migrationFile, nil
+}
 
-	if len(applied) == 0 {
-		fmt.Println("No migrations to rollback")
-		return nil
+// rollback executes a migration's DOWN SQL and removes its record, or marks
+// it as rolled back if soft
+func (e *Engine) rollback(ctx context.Context, file MigrationFile, soft bool) error {
+	if file.DownSQL == "" {
+		return newError(CodeMissingDown, file.Version, nil, "%s", missingDown(file))
 	}
 
-	// Get the last applied migration
-	lastMigration := applied[len(applied)-1]
+	fmt.Fprintf(e.out, "Rolling back migration %s: %s\n", file.Version, file.Name)
 
-	// Find the migration file
-	files, err := e.loadMigrationFiles()
+	downSQL, err := e.expandSQL(file.DownSQL)
 	if err != nil {
-		return fmt.Errorf("failed to load migration files: %w", err)
+		return newError(CodeRollbackFailed, file.Version, err, "failed to execute rollback for %s", file.Version)
 	}
 
-	var migrationFile *MigrationFile
-	for _, file := range files {
-		if file.Version == lastMigration.Version {
-			migrationFile = &file
-			break
+	// Execute DOWN SQL
+	execCtx, cancel := e.execContext(ctx)
+	err = e.executeSegments(execCtx, downSQL, file.DownNoTxn)
+	cancel()
+	if err != nil {
+		if e.timedOut(ctx, execCtx) {
+			return newError(CodeRollbackFailed, file.Version, err, "rollback of %s exceeded the timeout of %s", file.Version, e.timeout)
 		}
+		return newError(CodeRollbackFailed, file.Version, err, "failed to execute rollback for %s", file.Version)
 	}
 
-	if migrationFile == nil {
-		return fmt.Errorf("migration file not found for version %s", lastMigration.Version)
-	}
+	return e.removeRecord(ctx, file.Version, soft)
+}
 
-	if migrationFile.DownSQL == "" {
-		return fmt.Errorf("no DOWN migration found for version %s", lastMigration.Version)
+// removeRecord removes a migration's record, or marks it as rolled back if soft
+func (e *Engine) removeRecord(ctx context.Context, version string, soft bool) error {
+	remove := e.storage.RemoveMigrationContext
+	if soft {
+		remove = e.storage.MarkRolledBackContext
 	}
+	if err := remove(ctx, version); err != nil {
+		return newError(CodeRecordFailed, version, err, "failed to remove migration record %s", version)
+	}
+	return nil
+}
 
-	fmt.Printf("Rolling back migration %s: %s\n", migrationFile.Version, migrationFile.Name)
+// newRunID returns an identifier for an up run, unique across machines
+func newRunID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b)
+}
 
-	// Execute DOWN SQL
-	if err := e.storage.ExecuteSQL(migrationFile.DownSQL); err != nil {
-		return fmt.Errorf("failed to execute rollback for %s: %w", migrationFile.Version, err)
+// execContext returns the context for executing one migration's SQL within
+// the run's context ctx, limited by the timeout set with SetTimeout
+func (e *Engine) execContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if e.timeout <= 0 {
+		return ctx, func() {}
 	}
+	return context.WithTimeout(ctx, e.timeout)
+}
 
-	// Remove migration record
-	if err := e.storage.RemoveMigration(migrationFile.Version); err != nil {
-		return fmt.Errorf("failed to remove migration record %s: %w", migrationFile.Version, err)
+// timedOut reports whether execCtx, returned by execContext for the run's
+// context ctx, ended because the migration timeout passed rather than
+// because the run was cancelled
+func (e *Engine) timedOut(ctx, execCtx context.Context) bool {
+	return e.timeout > 0 && errors.Is(execCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil
+}
+
+// cancelled returns a CANCELLED error if ctx is done, reporting how many
+// migrations were run and how many remain. It is checked between
+// migrations.
+func (e *Engine) cancelled(ctx context.Context, version string, done, remaining int) error {
+	err := ctx.Err()
+	if err == nil {
+		return nil
 	}
+	fmt.Fprintf(e.out, "Cancelled after %d migration(s); %d migration(s) not run\n", done, remaining)
+	return newError(CodeCancelled, version, err, "cancelled before migration %s", version)
+}
 
-	fmt.Println("Migration rolled back successfully")
+// lock takes the migration lock so concurrent runs don't apply the same
+// migrations twice
+func (e *Engine) lock(ctx context.Context) error {
+	if err := e.storage.AcquireLockContext(ctx); err != nil {
+		if errors.Is(err, storage.ErrLocked) {
+			return newError(CodeLocked, "", err, "failed to acquire migration lock")
+		}
+		return newError(CodeStorageFailed, "", err, "failed to acquire migration lock")
+	}
 	return nil
 }
 
-// Status shows the current migration status
-func (e *Engine) Status() error {
-	// Get migration files
-	files, err := e.loadMigrationFiles()
-	if err != nil {
-		return fmt.Errorf("failed to load migration files: %w", err)
+// unlock releases the migration lock, warning if it could not be released
+func (e *Engine) unlock() {
+	if err := e.storage.ReleaseLock(); err != nil {
+		fmt.Fprintf(e.out, "Warning: failed to release migration lock: %v\n", err)
 	}
+}
 
-	// Get applied migrations
-	applied, err := e.storage.GetAppliedMigrations()
+// Status shows the current migration status
+func (e *Engine) Status(opts StatusOptions) error {
+	files, applied, err := e.migrationState(context.Background())
 	if err != nil {
-		return fmt.Errorf("failed to get applied migrations: %w", err)
+		return err
 	}
 
 	// Build set of applied versions
@@ -205,22 +1430,69 @@ func (e *Engine) Status() error {
 		appliedSet[m.Version] = m
 	}
 
+	late := outOfOrder(files, applied)
+
+	if opts.JSON {
+		if opts.Summary {
+			return fmt.Errorf("--summary cannot be combined with --json")
+		}
+		return e.printStatusJSON(files, appliedSet, late)
+	}
+
+	if opts.Summary {
+		fmt.Fprintln(e.out, statusSummary(files, applied, late))
+		return nil
+	}
+
 	if len(files) == 0 {
-		fmt.Println("No migrations found")
+		fmt.Fprintln(e.out, "No migrations found")
 		return nil
 	}
 
-	fmt.Println("Migration Status:")
-	fmt.Println("================")
+	fmt.Fprintln(e.out, "Migration Status:")
+	fmt.Fprintln(e.out, "================")
 
+	var latestPending *MigrationFile
 	for _, file := range files {
+		note := file.Note
 		if migration, isApplied := appliedSet[file.Version]; isApplied {
-			fmt.Printf("✓ %s_%s (applied: %s)\n",
+			if migration.Note != "" {
+				note = migration.Note
+			}
+			var took string
+			if opts.Durations && migration.Duration > 0 {
+				took = fmt.Sprintf(", took %s", migration.Duration)
+			}
+			fmt.Fprintf(e.out, "%s %s_%s (applied: %s%s)\n",
+				e.paint(colorGreen, "✓"),
 				file.Version,
 				file.Name,
-				migration.AppliedAt.Format("2006-01-02 15:04:05"))
+				migration.AppliedAt.Format("2006-01-02 15:04:05"),
+				took)
+		} else if late[file.Version] {
+			// Red sets out-of-order migrations apart from ordinary pending ones
+			fmt.Fprintf(e.out, "%s %s_%s %s\n", e.paint(colorRed, "✗"), file.Version, file.Name, e.paint(colorRed, "(pending, out of order)"))
+			latestPending = &file
+		} else {
+			fmt.Fprintf(e.out, "%s %s_%s (pending)\n", e.paint(colorYellow, "✗"), file.Version, file.Name)
+			latestPending = &file
+		}
+		if note != "" {
+			fmt.Fprintf(e.out, "    Note: %s\n", note)
+		}
+	}
+
+	if _, err := e.modifiedMigrations(files, applied); err != nil {
+		return err
+	}
+
+	if opts.PreviewLatest {
+		fmt.Fprintln(e.out)
+		if latestPending == nil {
+			fmt.Fprintln(e.out, "No pending migrations to preview")
 		} else {
-			fmt.Printf("✗ %s_%s (pending)\n", file.Version, file.Name)
+			fmt.Fprintf(e.out, "Latest pending migration %s_%s:\n\n", latestPending.Version, latestPending.Name)
+			fmt.Fprintln(e.out, latestPending.UpSQL)
 		}
 	}
 
@@ -231,86 +1503,339 @@ func (e *Engine) Status() error {
 func (e *Engine) Version() error {
 	version, err := e.storage.GetCurrentVersion()
 	if err != nil {
-		return fmt.Errorf("failed to get current version: %w", err)
+		return newError(CodeStorageFailed, "", err, "failed to get current version")
 	}
 
 	if version == "" {
-		fmt.Println("No migrations applied yet")
+		fmt.Fprintln(e.out, "No migrations applied yet")
 	} else {
-		fmt.Printf("Current version: %s\n", version)
+		fmt.Fprintf(e.out, "Current version: %s\n", version)
 	}
 
 	return nil
 }
 
+// PrintAppliedVersions prints the version of each applied migration, one
+// per line and nothing else, for use in scripts
+func (e *Engine) PrintAppliedVersions(newestFirst bool) error {
+	versions, err := e.storage.GetAppliedVersions(newestFirst)
+	if err != nil {
+		return newError(CodeStorageFailed, "", err, "failed to get applied versions")
+	}
+
+	for _, version := range versions {
+		fmt.Fprintln(e.out, version)
+	}
+	return nil
+}
+
 // loadMigrationFiles loads all migration files from the migrations directory
 func (e *Engine) loadMigrationFiles() ([]MigrationFile, error) {
+	paths, err := e.migrationPaths()
+	if err != nil {
+		return nil, err
+	}
+
 	var files []MigrationFile
+	for _, name := range paths {
+		file, err := e.parseMigrationFile(name)
+		if err != nil && e.skipInvalid {
+			fmt.Fprintf(e.warn, "%s skipping %s, it will not be applied: %v\n", e.paint(colorRed, "WARNING:"), e.displayPath(name), err)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w (use --skip-invalid to ignore it)", e.displayPath(name), err)
+		}
+		files = append(files, *file)
+	}
+
+	// Sort by version
+	sort.Slice(files, func(i, j int) bool {
+		return versionLess(files[i].Version, files[j].Version)
+	})
+
+	return files, nil
+}
 
-	err := filepath.WalkDir(e.migrationsDir, func(path string, d fs.DirEntry, err error) error {
+// migrationPaths returns the names in the engine's file system of all .sql
+// files in the migrations directory, except the DOWN files of split
+// migrations
+func (e *Engine) migrationPaths() ([]string, error) {
+	var paths []string
+
+	err := fs.WalkDir(e.fsys, e.root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
-		if d.IsDir() || !strings.HasSuffix(path, ".sql") {
+		// The DOWN file of a split migration is read with its UP file
+		if d.IsDir() || !strings.HasSuffix(path, ".sql") || isDownFile(path) {
 			return nil
 		}
 
-		file, err := e.parseMigrationFile(path)
-		if err != nil {
-			return fmt.Errorf("failed to parse %s: %w", path, err)
+		if e.ignorePattern != nil && e.ignorePattern.MatchString(d.Name()) {
+			return nil
 		}
 
-		files = append(files, *file)
+		paths = append(paths, path)
 		return nil
 	})
 
-	if err != nil {
-		return nil, err
+	// Report paths as the user gave them rather than relative to fsys
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		pathErr.Path = e.displayPath(pathErr.Path)
 	}
 
-	// Sort by version
-	sort.Slice(files, func(i, j int) bool {
-		return files[i].Version < files[j].Version
-	})
+	return paths, err
+}
 
-	return files, nil
+// relPath returns the slash-separated path of the file named name in the
+// engine's file system, relative to the migrations directory
+func (e *Engine) relPath(name string) string {
+	if e.root == "." {
+		return name
+	}
+	return strings.TrimPrefix(name, e.root+"/")
+}
+
+// displayPath returns the path shown to users for the file named name in
+// the engine's file system
+func (e *Engine) displayPath(name string) string {
+	return filepath.Join(e.migrationsDir, filepath.FromSlash(e.relPath(name)))
+}
+
+// expectedFilename describes the filenames the engine accepts, for errors
+func (e *Engine) expectedFilename() string {
+	if e.filenamePattern.String() == DefaultFilenamePattern {
+		return "expected <version>_<name>.sql such as 001_create_users.sql"
+	}
+	return fmt.Sprintf("expected a name matching %s", e.filenamePattern)
 }
 
-// parseMigrationFile parses a single migration file
-func (e *Engine) parseMigrationFile(path string) (*MigrationFile, error) {
-	// Parse filename for version and name
-	filename := filepath.Base(path)
-	re := regexp.MustCompile(`^(\d+)_(.+)\.sql$`)
-	matches := re.FindStringSubmatch(filename)
+// parseMigrationFile parses the migration file named name in the engine's
+// file system
+func (e *Engine) parseMigrationFile(name string) (*MigrationFile, error) {
+	path := e.displayPath(name)
+
+	// Parse filename for version and name; the UP file of a split
+	// migration is matched as if it were a single file
+	filename := pathpkg.Base(name)
+	matchName := filename
+	split := strings.HasSuffix(filename, upSuffix)
+	if split {
+		matchName = strings.TrimSuffix(filename, upSuffix) + ".sql"
+	}
+	matches := e.filenamePattern.FindStringSubmatch(matchName)
+
+	if matches == nil {
+		return nil, newError(CodeInvalidMigration, "", nil, "invalid migration filename format: %s, %s", filename, e.expectedFilename())
+	}
+
+	version := matches[e.filenamePattern.SubexpIndex("version")]
+	migrationName := matches[e.filenamePattern.SubexpIndex("name")]
+
+	if split {
+		return e.parseSplitMigration(name, version, migrationName)
+	}
 
-	if len(matches) != 3 {
-		return nil, fmt.Errorf("invalid migration filename format: %s", filename)
+	if e.streaming {
+		markers, err := e.detectFileMarkers(name)
+		if err != nil {
+			return nil, err
+		}
+		var scanMarkers sectionMarkers
+		if markers != nil {
+			scanMarkers = *markers
+		}
+		downSQL, directives, err := parseMigrationStream(e.fsys, name, scanMarkers)
+		if err != nil {
+			return nil, err
+		}
+		header, err := readHeader(e.fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		upNoTxn, err := e.upNoTxn(name, markers)
+		if err != nil {
+			return nil, err
+		}
+		return &MigrationFile{
+			Version:      version,
+			Name:         migrationName,
+			Description:  header.description,
+			Note:         header.note,
+			Created:      header.created,
+			Path:         path,
+			DownSQL:      downSQL,
+			Manual:       directives["migrate:manual"],
+			Independent:  directives["migrate:independent"],
+			Irreversible: directives["migrate:irreversible"],
+			UpNoTxn:      upNoTxn,
+			DownNoTxn:    leadingDirective(strings.NewReader(downSQL), "txn:none"),
+			markers:      markers,
+			fsName:       name,
+		}, nil
 	}
 
-	version := matches[1]
-	name := matches[2]
+	if err := e.checkSize(name, version); err != nil {
+		return nil, err
+	}
 
 	// Read file content
-	content, err := os.ReadFile(path)
+	content, err := fs.ReadFile(e.fsys, name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
-	// Parse UP and DOWN sections
-	upSQL, downSQL := parseSQL(string(content))
+	// Parse UP and DOWN sections, treating files without markers as UP only
+	var markers *sectionMarkers
+	if !e.upOnly {
+		if found, ok := detectMarkers(strings.NewReader(string(content)), e.markers); ok {
+			markers = &found
+		}
+	}
+	upSQL, downSQL := strings.TrimSpace(string(content)), ""
+	if markers != nil {
+		upSQL, downSQL = parseSections(string(content), *markers)
+	}
+	header := parseHeader(strings.NewReader(string(content)))
+	manual := hasDirective(string(content), "migrate:manual")
+	independent := hasDirective(string(content), "migrate:independent")
+	irreversible := hasDirective(string(content), "migrate:irreversible")
 
 	return &MigrationFile{
-		Version: version,
-		Name:    name,
-		Path:    path,
-		UpSQL:   upSQL,
-		DownSQL: downSQL,
+		Version:      version,
+		Name:         migrationName,
+		Description:  header.description,
+		Note:         header.note,
+		Created:      header.created,
+		Path:         path,
+		UpSQL:        upSQL,
+		DownSQL:      downSQL,
+		Manual:       manual,
+		Independent:  independent,
+		Irreversible: irreversible,
+		UpNoTxn:      leadingDirective(strings.NewReader(upSQL), "txn:none"),
+		DownNoTxn:    leadingDirective(strings.NewReader(downSQL), "txn:none"),
+		markers:      markers,
+		fsName:       name,
 	}, nil
 }
 
-// parseSQL parses UP and DOWN SQL from migration content
+// upNoTxn reports whether the UP section of the file named name starts
+// with a "-- txn:none" line, reading only up to its first statement
+func (e *Engine) upNoTxn(name string, markers *sectionMarkers) (bool, error) {
+	var r io.ReadCloser
+	var err error
+	if markers == nil {
+		r, err = e.fsys.Open(name)
+	} else {
+		r, err = openUpSection(e.fsys, name, *markers)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer r.Close()
+
+	return leadingDirective(r, "txn:none"), nil
+}
+
+// checkSize rejects the file named name if it exceeds the maximum
+// migration size, before it is read into memory
+func (e *Engine) checkSize(name, version string) error {
+	if e.maxMigrationSize <= 0 {
+		return nil
+	}
+	info, err := fs.Stat(e.fsys, name)
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	if info.Size() > e.maxMigrationSize {
+		return newError(CodeInvalidMigration, version, nil,
+			"migration file %s is %d bytes, exceeding the maximum of %d bytes", pathpkg.Base(name), info.Size(), e.maxMigrationSize)
+	}
+	return nil
+}
+
+// detectFileMarkers returns the section markers used by the file named
+// name, or nil if the whole file is the UP section
+func (e *Engine) detectFileMarkers(name string) (*sectionMarkers, error) {
+	if e.upOnly {
+		return nil, nil
+	}
+
+	f, err := e.fsys.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer f.Close()
+
+	if markers, ok := detectMarkers(f, e.markers); ok {
+		return &markers, nil
+	}
+	return nil, nil
+}
+
+// sectionMarkers are the comment texts that start the UP and DOWN sections
+type sectionMarkers struct {
+	up   string
+	down string
+}
+
+var (
+	// defaultMarkers are the section markers written by Create
+	defaultMarkers = sectionMarkers{up: "==== UP ====", down: "==== DOWN ===="}
+	// gooseMarkers are the section markers used by goose
+	gooseMarkers = sectionMarkers{up: "+goose Up", down: "+goose Down"}
+	// sqlMigrateMarkers are the section markers used by sql-migrate
+	sqlMigrateMarkers = sectionMarkers{up: "+migrate Up", down: "+migrate Down"}
+)
+
+// knownMarkers are the marker styles recognized in any project, so files
+// converted from other tools can sit next to native ones
+var knownMarkers = []sectionMarkers{defaultMarkers, gooseMarkers, sqlMigrateMarkers}
+
+// detectMarkers returns the marker style of the first section marker found
+// in a comment line of r, checking preferred before knownMarkers
+func detectMarkers(r io.Reader, preferred sectionMarkers) (sectionMarkers, bool) {
+	candidates := append([]sectionMarkers{preferred}, knownMarkers...)
+
+	// Read with a bufio.Reader rather than a Scanner so that long lines in
+	// large migrations can't cut detection short
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		for _, markers := range candidates {
+			if isMarker(line, markers.up) || isMarker(line, markers.down) {
+				return markers, true
+			}
+		}
+		if err != nil {
+			return sectionMarkers{}, false
+		}
+	}
+}
+
+// isMarker reports whether line is a comment line holding marker, so that a
+// marker quoted in a string literal or following SQL never starts a section
+func isMarker(line, marker string) bool {
+	line = strings.TrimSpace(line)
+	return strings.HasPrefix(line, "--") && strings.Contains(line, marker)
+}
+
+// parseSQL parses UP and DOWN SQL from migration content in any known
+// marker style, treating content without markers as UP only
 func parseSQL(content string) (upSQL, downSQL string) {
+	markers, ok := detectMarkers(strings.NewReader(content), defaultMarkers)
+	if !ok {
+		return strings.TrimSpace(content), ""
+	}
+	return parseSections(content, markers)
+}
+
+// parseSections parses UP and DOWN SQL from content using the given markers
+func parseSections(content string, markers sectionMarkers) (upSQL, downSQL string) {
 	scanner := bufio.NewScanner(strings.NewReader(content))
 
 	var currentSection string
@@ -319,12 +1844,12 @@ func parseSQL(content string) (upSQL, downSQL string) {
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 
-		if strings.Contains(line, "==== UP ====") {
+		if isMarker(line, markers.up) {
 			currentSection = "up"
 			continue
 		}
 
-		if strings.Contains(line, "==== DOWN ====") {
+		if isMarker(line, markers.down) {
 			currentSection = "down"
 			continue
 		}
@@ -341,30 +1866,282 @@ func parseSQL(content string) (upSQL, downSQL string) {
 		strings.TrimSpace(strings.Join(downLines, "\n"))
 }
 
-// getNextVersion returns the next migration version number
+// readDownScript reads rollback SQL from path, using its DOWN section when
+// the file has section markers and the whole content otherwise
+func readDownScript(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read rollback file: %w", err)
+	}
+
+	if _, ok := detectMarkers(strings.NewReader(string(content)), defaultMarkers); ok {
+		_, downSQL := parseSQL(string(content))
+		return downSQL, nil
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// executeUp runs a migration's UP SQL, streaming it from disk if enabled
+func (e *Engine) executeUp(ctx context.Context, file MigrationFile) error {
+	if !e.streaming {
+		sql, err := e.expandSQL(file.UpSQL)
+		if err != nil {
+			return err
+		}
+		return e.executeSegments(ctx, sql, file.UpNoTxn)
+	}
+
+	r, err := e.openUp(file)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if file.UpNoTxn {
+		return e.storage.ExecuteStreamNoTxContext(ctx, r)
+	}
+	return e.storage.ExecuteStreamContext(ctx, r)
+}
+
+// openUp opens the UP section of a migration file for streaming
+func (e *Engine) openUp(file MigrationFile) (io.ReadCloser, error) {
+	if file.markers == nil {
+		return e.fsys.Open(file.fsName)
+	}
+	return openUpSection(e.fsys, file.fsName, *file.markers)
+}
+
+// executeSegments executes sql split at "-- migrate:commit" lines, each
+// segment in its own transaction, or all in the transaction of an atomic
+// run, stopping at the first failure. With noTxn the statements run outside
+// any transaction instead.
+func (e *Engine) executeSegments(ctx context.Context, sql string, noTxn bool) error {
+	exec := func(script string) error {
+		return e.storage.ExecuteSQLContext(ctx, script)
+	}
+	switch {
+	case noTxn:
+		exec = func(script string) error {
+			return e.storage.ExecuteSQLNoTxContext(ctx, script)
+		}
+	case e.tx != nil:
+		exec = func(script string) error {
+			return e.storage.ExecuteSQLTxContext(ctx, e.tx, script)
+		}
+	}
+
+	segments := splitCommitSegments(sql)
+	for i, segment := range segments {
+		if err := exec(segment); err != nil {
+			if len(segments) > 1 {
+				return fmt.Errorf("segment %d of %d: %w", i+1, len(segments), err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// splitCommitSegments splits sql at "-- migrate:commit" lines, dropping
+// empty segments
+func splitCommitSegments(sql string) []string {
+	var segments []string
+	var current []string
+
+	flush := func() {
+		if segment := strings.TrimSpace(strings.Join(current, "\n")); segment != "" {
+			segments = append(segments, segment)
+		}
+		current = nil
+	}
+
+	for _, line := range strings.Split(sql, "\n") {
+		if isDirective(strings.TrimSpace(line), "migrate:commit") {
+			flush()
+			continue
+		}
+		current = append(current, line)
+	}
+	flush()
+
+	return segments
+}
+
+// hasDirective reports whether content contains a "-- <name>" directive line
+func hasDirective(content, name string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		if isDirective(strings.TrimSpace(line), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// leadingDirective reports whether the "-- <name>" directive appears among
+// the comment lines before the first statement read from r
+func leadingDirective(r io.Reader, name string) bool {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if isDirective(line, name) {
+			return true
+		}
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+		if err != nil {
+			return false
+		}
+	}
+}
+
+// missingDown describes why file, which has no DOWN section, can't be
+// rolled back
+func missingDown(file MigrationFile) string {
+	if file.Irreversible {
+		return fmt.Sprintf("migration %s (%s) is irreversible", file.Version, file.Name)
+	}
+	return fmt.Sprintf("no DOWN migration found for version %s", file.Version)
+}
+
+// isDirective reports whether a trimmed line is the "-- <name>" directive
+func isDirective(line, name string) bool {
+	if !strings.HasPrefix(line, "--") {
+		return false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(line, "--")) == name
+}
+
+// checkApproval verifies that version is a pending manual migration
+func checkApproval(files []MigrationFile, appliedSet map[string]bool, version string) error {
+	for _, file := range files {
+		if file.Version != version {
+			continue
+		}
+		if appliedSet[version] {
+			return fmt.Errorf("migration %s is already applied", version)
+		}
+		if !file.Manual {
+			return fmt.Errorf("migration %s does not require manual approval", version)
+		}
+		return nil
+	}
+	return newError(CodeFileNotFound, version, nil, "migration file not found for version %s", version)
+}
+
+// onlyMigration returns the pending migration with version for up --only.
+// Unless force is set, or whenever strict is, it refuses one that would run
+// out of order: while older migrations are still pending, or after a newer
+// one was applied.
+func (e *Engine) onlyMigration(files []MigrationFile, appliedSet, late map[string]bool, version string, force, strict bool) (MigrationFile, error) {
+	var older []string
+	for _, file := range files {
+		if file.Version != version {
+			if !appliedSet[file.Version] && versionLess(file.Version, version) {
+				older = append(older, file.Version)
+			}
+			continue
+		}
+		if appliedSet[version] {
+			return file, fmt.Errorf("migration %s is already applied", version)
+		}
+
+		var reason string
+		switch {
+		case len(older) > 0:
+			reason = fmt.Sprintf("older migrations are still pending: %s", strings.Join(older, ", "))
+		case late[version]:
+			reason = "a newer migration is already applied"
+		default:
+			return file, nil
+		}
+		if strict {
+			return file, newError(CodeOutOfOrder, version, nil, "migration %s would be applied out of order, %s", version, reason)
+		}
+		if !force {
+			return file, newError(CodeOutOfOrder, version, nil, "migration %s would be applied out of order, %s; use --force to apply it anyway", version, reason)
+		}
+		fmt.Fprintf(e.out, "Warning: applying migration %s out of order, %s\n", version, reason)
+		return file, nil
+	}
+	return MigrationFile{}, newError(CodeFileNotFound, version, nil, "migration file not found for version %s", version)
+}
+
+// getNextVersion returns the next migration version number, or the
+// current UTC time for timestamp versions
 func (e *Engine) getNextVersion() (string, error) {
+	if e.timestamps {
+		return time.Now().UTC().Format(timestampVersionLayout), nil
+	}
+
+	// A missing directory is checked for up front: the error from walking
+	// it is wrapped and can't be told apart reliably afterwards
+	if _, err := fs.Stat(e.fsys, e.root); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Sprintf("%0*d", e.versionWidth, 1), nil
+	}
+
 	files, err := e.loadMigrationFiles()
 	if err != nil {
-		// If directory doesn't exist, start from 001
-		if os.IsNotExist(err) {
-			return "001", nil
-		}
 		return "", err
 	}
 
 	if len(files) == 0 {
-		return "001", nil
+		return fmt.Sprintf("%0*d", e.versionWidth, 1), nil
+	}
+
+	// Warn when existing versions don't share the configured width
+	widths := make(map[int]bool)
+	for _, file := range files {
+		widths[len(file.Version)] = true
+	}
+	if len(widths) > 1 {
+		fmt.Fprintln(e.out, "Warning: existing migrations mix version widths")
+	} else if !widths[e.versionWidth] {
+		fmt.Fprintf(e.out, "Warning: existing migrations use %d-digit versions, generating %d digits (see --version-width)\n",
+			len(files[0].Version), e.versionWidth)
 	}
 
 	// Get the last version and increment
-	lastFile := files[len(files)-1]
-	lastVersion, err := strconv.Atoi(lastFile.Version)
+	return e.bumpVersion(files[len(files)-1].Version)
+}
+
+// bumpVersion returns the version after version
+func (e *Engine) bumpVersion(version string) (string, error) {
+	n, err := strconv.Atoi(version)
 	if err != nil {
-		return "", fmt.Errorf("invalid version format: %s", lastFile.Version)
+		return "", fmt.Errorf("invalid version format: %s", version)
+	}
+	return fmt.Sprintf("%0*d", e.versionWidth, n+1), nil
+}
+
+// versionLess orders versions numerically when both are numeric, so that
+// differently padded versions like 0001 and 002 sort correctly, and
+// lexicographically otherwise
+func versionLess(a, b string) bool {
+	if isNumeric(a) && isNumeric(b) {
+		ta, tb := strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+		if len(ta) != len(tb) {
+			return len(ta) < len(tb)
+		}
+		if ta != tb {
+			return ta < tb
+		}
 	}
+	return a < b
+}
 
-	nextVersion := lastVersion + 1
-	return fmt.Sprintf("%03d", nextVersion), nil
+// isNumeric reports whether s is a non-empty string of ASCII digits
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
 }
 
 // sanitizeName sanitizes a migration name for use in filename
@@ -0,0 +1,144 @@
+package migration
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSkipInvalidWarnsOutsideOutput(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"001_create_users.sql": "-- ==== UP ====\nCREATE TABLE users (id INTEGER);\n",
+		"notes.sql":            "SELECT 1;\n",
+	})
+
+	e := NewEngine(nil, dir)
+	e.SetSkipInvalid(true)
+	var out, warn bytes.Buffer
+	e.SetOutput(&out)
+	e.SetWarningOutput(&warn)
+
+	files, err := e.loadMigrationFiles()
+	if err != nil {
+		t.Fatalf("loadMigrationFiles: %v", err)
+	}
+	if len(files) != 1 || files[0].Version != "001" {
+		t.Errorf("got %+v, want only 001", files)
+	}
+	if !strings.Contains(warn.String(), "notes.sql, it will not be applied") {
+		t.Errorf("warning output = %q, want the skipped file", warn.String())
+	}
+	if out.Len() != 0 {
+		t.Errorf("output = %q, want nothing", out.String())
+	}
+}
+
+func TestUpOnly(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"001_create_users.sql": "-- ==== UP ====\nCREATE TABLE users (id INTEGER);\n",
+		"002_create_posts.sql": "-- ==== UP ====\nCREATE TABLE posts (id INTEGER);\n",
+	})
+
+	s := openTempStorage(t)
+	e := NewEngine(s, dir)
+	e.SetOutput(io.Discard)
+	e.SetPromptOutput(io.Discard)
+
+	applied := func() []string {
+		t.Helper()
+		versions, err := s.GetAppliedVersions(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return versions
+	}
+
+	if err := e.Up(UpOptions{Only: "002"}); err == nil {
+		t.Error("--only applied 002 ahead of 001 without --force")
+	}
+	if err := e.Up(UpOptions{Only: "002", Force: true, Strict: true}); err == nil {
+		t.Error("--only --force applied 002 out of order despite --strict")
+	}
+	if err := e.Up(UpOptions{Only: "001", Input: strings.NewReader("n\n")}); err == nil {
+		t.Error("--only applied 001 after the confirmation was declined")
+	}
+	if got := applied(); len(got) != 0 {
+		t.Fatalf("applied %v, want nothing yet", got)
+	}
+
+	if err := e.Up(UpOptions{Only: "001", Input: strings.NewReader("y\n")}); err != nil {
+		t.Fatalf("Up --only 001: %v", err)
+	}
+	if got := applied(); len(got) != 1 || got[0] != "001" {
+		t.Errorf("applied %v, want only 001", got)
+	}
+}
+
+func TestUpAtomicRollsBackEarlierMigrations(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"001_create_users.sql": "-- ==== UP ====\nCREATE TABLE users (id INTEGER);\n",
+		"002_broken.sql":       "-- ==== UP ====\nCREATE TABLE posts (id INTEGER);\nINSERT INTO missing VALUES (1);\n",
+	})
+
+	s := openTempStorage(t)
+	e := NewEngine(s, dir)
+	var out bytes.Buffer
+	e.SetOutput(&out)
+
+	if err := e.Up(UpOptions{Atomic: true}); err == nil {
+		t.Fatal("Up succeeded with a broken migration")
+	}
+
+	versions, err := s.GetAppliedVersions(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 0 {
+		t.Errorf("applied versions = %v, want none", versions)
+	}
+	for _, table := range []string{"users", "posts"} {
+		if _, _, err := s.DumpRows(table, 1); err == nil {
+			t.Errorf("table %s exists after the rollback", table)
+		}
+	}
+	if !strings.Contains(out.String(), "none of the 2 migration(s) run were kept") {
+		t.Errorf("output = %q, want both migrations counted", out.String())
+	}
+}
+
+func TestUpOrdersVersionsNumerically(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"999_a.sql":  "-- ==== UP ====\nCREATE TABLE a (id INTEGER);\n",
+		"1000_b.sql": "-- ==== UP ====\nCREATE TABLE b AS SELECT id FROM a;\n",
+	})
+
+	s := openTempStorage(t)
+	e := NewEngine(s, dir)
+	e.SetOutput(io.Discard)
+	e.SetWarningOutput(io.Discard)
+
+	files, err := e.loadMigrationFiles()
+	if err != nil {
+		t.Fatalf("loadMigrationFiles: %v", err)
+	}
+	if len(files) != 2 || files[0].Version != "999" || files[1].Version != "1000" {
+		t.Fatalf("got %+v, want 999 then 1000", files)
+	}
+
+	// 1000 reads the table 999 creates, so it fails if run first
+	if err := e.Up(UpOptions{}); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	versions, err := s.GetAppliedVersions(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0] != "999" || versions[1] != "1000" {
+		t.Errorf("applied versions = %v, want [999 1000]", versions)
+	}
+}
@@ -0,0 +1,87 @@
+package migration
+
+import "fmt"
+
+// Error codes attached to migration errors for machine-readable reporting
+const (
+	CodeMigrationFailed  = "MIGRATION_FAILED"
+	CodeRecordFailed     = "RECORD_FAILED"
+	CodeRollbackFailed   = "ROLLBACK_FAILED"
+	CodeFileNotFound     = "MIGRATION_FILE_NOT_FOUND"
+	CodeMissingDown      = "MISSING_DOWN"
+	CodeInvalidMigration = "INVALID_MIGRATION"
+	CodeStorageFailed    = "STORAGE_FAILED"
+	CodeApprovalRequired = "APPROVAL_REQUIRED"
+	CodeSchemaMismatch   = "SCHEMA_MISMATCH"
+	CodeAuthFailed       = "AUTH_FAILED"
+	CodeLocked           = "LOCKED"
+	CodeApplyTimeout     = "APPLY_TIMEOUT"
+	CodeManifestMismatch = "MANIFEST_MISMATCH"
+	CodeReadOnly         = "READ_ONLY"
+	CodeChecksumMismatch = "CHECKSUM_MISMATCH"
+	CodeOutOfOrder       = "OUT_OF_ORDER"
+	CodeCancelled        = "CANCELLED"
+	CodeUnknown          = "ERROR"
+)
+
+// Sentinel errors, matched with errors.Is by code
+var (
+	ErrMigrationFailed  = &Error{Code: CodeMigrationFailed}
+	ErrRecordFailed     = &Error{Code: CodeRecordFailed}
+	ErrRollbackFailed   = &Error{Code: CodeRollbackFailed}
+	ErrFileNotFound     = &Error{Code: CodeFileNotFound}
+	ErrMissingDown      = &Error{Code: CodeMissingDown}
+	ErrInvalidMigration = &Error{Code: CodeInvalidMigration}
+	ErrStorageFailed    = &Error{Code: CodeStorageFailed}
+	ErrApprovalRequired = &Error{Code: CodeApprovalRequired}
+	ErrSchemaMismatch   = &Error{Code: CodeSchemaMismatch}
+	ErrAuthFailed       = &Error{Code: CodeAuthFailed}
+	ErrLocked           = &Error{Code: CodeLocked}
+	ErrApplyTimeout     = &Error{Code: CodeApplyTimeout}
+	ErrManifestMismatch = &Error{Code: CodeManifestMismatch}
+	ErrReadOnly         = &Error{Code: CodeReadOnly}
+	ErrChecksumMismatch = &Error{Code: CodeChecksumMismatch}
+	ErrOutOfOrder       = &Error{Code: CodeOutOfOrder}
+	ErrCancelled        = &Error{Code: CodeCancelled}
+)
+
+// Error is a migration error carrying a code and the version it concerns
+type Error struct {
+	Code    string
+	Version string
+	Message string
+	Err     error
+}
+
+// newError creates an Error with a formatted message, wrapping err if non-nil
+func newError(code, version string, err error, format string, args ...any) *Error {
+	return &Error{
+		Code:    code,
+		Version: version,
+		Message: fmt.Sprintf(format, args...),
+		Err:     err,
+	}
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = e.Code
+	}
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", msg, e.Err)
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is an Error with the same code
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
@@ -0,0 +1,49 @@
+package migration
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// envRefPattern matches the ${NAME} references replaced when environment
+// expansion is enabled
+var envRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// SetExpandEnv makes the engine replace ${NAME} in the SQL of migrations
+// with the value of the environment variable NAME just before running or
+// printing it. Checksums are of the expanded SQL, so checking applied
+// migrations for changes also needs their variables. Unset variables are
+// errors.
+func (e *Engine) SetExpandEnv(expand bool) {
+	e.expandEnv = expand
+}
+
+// expandSQL returns sql with its environment variables expanded when
+// expansion is enabled, or unchanged otherwise
+func (e *Engine) expandSQL(sql string) (string, error) {
+	if !e.expandEnv {
+		return sql, nil
+	}
+	return expandEnv(sql)
+}
+
+// expandEnv replaces every ${NAME} in sql, failing with the names of the
+// variables that are not set
+func expandEnv(sql string) (string, error) {
+	var unset []string
+	expanded := envRefPattern.ReplaceAllStringFunc(sql, func(ref string) string {
+		name := envRefPattern.FindStringSubmatch(ref)[1]
+		value, ok := os.LookupEnv(name)
+		if !ok && !slices.Contains(unset, name) {
+			unset = append(unset, name)
+		}
+		return value
+	})
+	if len(unset) > 0 {
+		return "", fmt.Errorf("environment variable(s) not set: %s", strings.Join(unset, ", "))
+	}
+	return expanded, nil
+}
@@ -0,0 +1,61 @@
+package migration
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestExpandEnvOnlyWhenRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"001_tenant.sql": "-- ==== UP ====\nCREATE TABLE ${MIGRATE_TEST_TENANT}_invoices (id INTEGER);\n-- ==== DOWN ====\nDROP TABLE ${MIGRATE_TEST_TENANT}_invoices;\n",
+	})
+
+	e := NewEngine(nil, dir)
+	e.SetExpandEnv(true)
+	e.SetOutput(io.Discard)
+
+	// The variable is not set yet, which must not fail loading
+	files, err := e.loadMigrationFiles()
+	if err != nil {
+		t.Fatalf("loadMigrationFiles: %v", err)
+	}
+	if !strings.Contains(files[0].UpSQL, "${MIGRATE_TEST_TENANT}") {
+		t.Errorf("UP SQL was expanded when loading: %q", files[0].UpSQL)
+	}
+	if _, err := e.expandSQL(files[0].DownSQL); err == nil || !strings.Contains(err.Error(), "MIGRATE_TEST_TENANT") {
+		t.Errorf("expandSQL with the variable unset = %v, want an error naming it", err)
+	}
+
+	t.Setenv("MIGRATE_TEST_TENANT", "acme")
+	up, err := e.expandSQL(files[0].UpSQL)
+	if err != nil || up != "CREATE TABLE acme_invoices (id INTEGER);" {
+		t.Errorf("expandSQL(UP) = %q, %v", up, err)
+	}
+}
+
+func TestExpandEnvChecksumDetectsNewValues(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"001_tenant.sql": "-- ==== UP ====\nCREATE TABLE ${MIGRATE_TEST_TENANT}_invoices (id INTEGER);\n",
+	})
+
+	s := openTempStorage(t)
+	e := NewEngine(s, dir)
+	e.SetExpandEnv(true)
+	e.SetOutput(io.Discard)
+
+	t.Setenv("MIGRATE_TEST_TENANT", "acme")
+	if err := e.Up(UpOptions{}); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := e.Up(UpOptions{StrictChecksums: true}); err != nil {
+		t.Errorf("Up with the same value: %v", err)
+	}
+
+	t.Setenv("MIGRATE_TEST_TENANT", "globex")
+	if err := e.Up(UpOptions{StrictChecksums: true}); err == nil {
+		t.Error("Up --strict-checksums accepted SQL expanded with a different value")
+	}
+}
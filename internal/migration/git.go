@@ -0,0 +1,97 @@
+package migration
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// uncommittedFiles returns the files under dir that are untracked or have
+// changes not yet committed, as slash-separated paths relative to dir.
+// ok is false when dir is not inside a git repository with commits.
+func uncommittedFiles(dir string) (files map[string]bool, ok bool) {
+	if err := exec.Command("git", "-C", dir, "rev-parse", "--verify", "--quiet", "HEAD").Run(); err != nil {
+		return nil, false
+	}
+
+	changed, err := exec.Command("git", "-C", dir, "diff", "--name-only", "--relative", "HEAD", "--", ".").Output()
+	if err != nil {
+		return nil, false
+	}
+
+	untracked, err := exec.Command("git", "-C", dir, "ls-files", "--others", "--exclude-standard", "--", ".").Output()
+	if err != nil {
+		return nil, false
+	}
+
+	files = make(map[string]bool)
+	for _, line := range strings.Split(string(changed)+string(untracked), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files[line] = true
+		}
+	}
+
+	return files, true
+}
+
+// uncommitted returns the uncommitted files of the migrations directory,
+// as uncommittedFiles does. ok is false when migrations aren't read from
+// a directory on disk.
+func (e *Engine) uncommitted() (files map[string]bool, ok bool) {
+	if !e.onDisk {
+		return nil, false
+	}
+	return uncommittedFiles(e.migrationsDir)
+}
+
+// exportGitRef copies the files under dir as they were at the git commit ref
+// into a new temporary directory, which the caller must remove. The working
+// tree is not touched.
+func exportGitRef(dir, ref string) (string, error) {
+	// A ref starting with a dash would be read by git as an option
+	if ref == "" || strings.HasPrefix(ref, "-") {
+		return "", fmt.Errorf("invalid git ref %q", ref)
+	}
+	if err := exec.Command("git", "-C", dir, "rev-parse", "--is-inside-work-tree").Run(); err != nil {
+		return "", fmt.Errorf("%s is not inside a git repository", dir)
+	}
+	if err := exec.Command("git", "-C", dir, "rev-parse", "--verify", "--quiet", ref+"^{commit}").Run(); err != nil {
+		return "", fmt.Errorf("invalid git ref %q", ref)
+	}
+
+	listing, err := exec.Command("git", "-C", dir, "ls-tree", "-r", "-z", "--name-only", ref, "--", ".").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to list files at %s: %w", ref, err)
+	}
+
+	tmp, err := os.MkdirTemp("", "turso-migrate-")
+	if err != nil {
+		return "", err
+	}
+
+	for _, name := range strings.Split(string(listing), "\x00") {
+		if name == "" {
+			continue
+		}
+
+		content, err := exec.Command("git", "-C", dir, "show", ref+":./"+name).Output()
+		if err != nil {
+			os.RemoveAll(tmp)
+			return "", fmt.Errorf("failed to read %s at %s: %w", name, ref, err)
+		}
+
+		path := filepath.Join(tmp, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			os.RemoveAll(tmp)
+			return "", err
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			os.RemoveAll(tmp)
+			return "", err
+		}
+	}
+
+	return tmp, nil
+}
@@ -0,0 +1,35 @@
+package migration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportGitRef(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"001_create_users.sql": "-- ==== UP ====\nCREATE TABLE users (id INTEGER);\n",
+	})
+	gitInit(t, dir)
+
+	tmp, err := exportGitRef(dir, "HEAD")
+	if err != nil {
+		t.Fatalf("exportGitRef(HEAD): %v", err)
+	}
+	defer os.RemoveAll(tmp)
+	if _, err := os.Stat(filepath.Join(tmp, "001_create_users.sql")); err != nil {
+		t.Errorf("file not exported: %v", err)
+	}
+
+	out := filepath.Join(t.TempDir(), "out")
+	for _, ref := range []string{"--output=" + out, "-h", ""} {
+		if tmp, err := exportGitRef(dir, ref); err == nil {
+			os.RemoveAll(tmp)
+			t.Errorf("exportGitRef(%q) succeeded, want an invalid ref error", ref)
+		}
+	}
+	if _, err := os.Stat(out); err == nil {
+		t.Error("a ref was passed to git as an option")
+	}
+}
@@ -0,0 +1,109 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+)
+
+// Goto brings the database to exactly the target version: applied
+// migrations newer than target are rolled back, newest first, then pending
+// migrations up to and including target are applied in order
+func (e *Engine) Goto(target string) error {
+	return e.GotoContext(context.Background(), target)
+}
+
+// GotoContext is Goto with a context. Once ctx is cancelled no further
+// migrations are rolled back or applied.
+func (e *Engine) GotoContext(ctx context.Context, target string) error {
+	if err := e.checkWritable("migrate to a version"); err != nil {
+		return err
+	}
+
+	files, err := e.loadMigrationFiles()
+	if err != nil {
+		return fmt.Errorf("failed to load migration files: %w", err)
+	}
+
+	found := false
+	for _, file := range files {
+		if file.Version == target {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return newError(CodeFileNotFound, target, nil, "no migration file for target version %s", target)
+	}
+
+	if err := e.lock(ctx); err != nil {
+		return err
+	}
+	defer e.unlock()
+
+	applied, err := e.storage.GetAppliedMigrationsContext(ctx)
+	if err != nil {
+		return newError(CodeStorageFailed, "", err, "failed to get applied migrations")
+	}
+
+	appliedSet := make(map[string]bool)
+	for _, m := range applied {
+		appliedSet[m.Version] = true
+	}
+
+	// Applied migrations are ordered by version, so walk them backwards
+	var down []MigrationFile
+	for i := len(applied) - 1; i >= 0; i-- {
+		if !versionLess(target, applied[i].Version) {
+			break
+		}
+		file, err := findDownFile(files, applied[i], "")
+		if err != nil {
+			return err
+		}
+		if file.DownSQL == "" {
+			return newError(CodeMissingDown, file.Version, nil, "cannot go to %s: %s", target, missingDown(*file))
+		}
+		down = append(down, *file)
+	}
+
+	var up []MigrationFile
+	for _, file := range files {
+		if versionLess(target, file.Version) {
+			break
+		}
+		if appliedSet[file.Version] {
+			continue
+		}
+		if file.Manual {
+			return newError(CodeApprovalRequired, file.Version, nil, "migration %s requires manual approval; apply it with up --approve %s first", file.Version, file.Version)
+		}
+		up = append(up, file)
+	}
+
+	if len(down) == 0 && len(up) == 0 {
+		fmt.Fprintf(e.out, "Already at version %s\n", target)
+		return nil
+	}
+
+	for i, file := range down {
+		if err := e.cancelled(ctx, file.Version, i, len(down)+len(up)-i); err != nil {
+			return err
+		}
+		if err := e.rollback(ctx, file, false); err != nil {
+			return err
+		}
+	}
+
+	runID := newRunID()
+	for i, file := range up {
+		if err := e.cancelled(ctx, file.Version, len(down)+i, len(up)-i); err != nil {
+			return err
+		}
+		if err := e.applyMigration(ctx, file, runID); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(e.out, "Rolled back %d and applied %d migration(s); now at version %s\n", len(down), len(up), target)
+	return nil
+}
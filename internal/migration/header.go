@@ -0,0 +1,72 @@
+package migration
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+// createdLayout is the format of the "-- Created:" header written by Create
+const createdLayout = "2006-01-02 15:04:05"
+
+// migrationHeader holds the fields of a migration's leading comment header
+type migrationHeader struct {
+	description string
+	note        string
+	created     time.Time
+}
+
+// readHeader reads the comment header of the file named name in fsys
+func readHeader(fsys fs.FS, name string) (migrationHeader, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return migrationHeader{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer f.Close()
+
+	return parseHeader(f), nil
+}
+
+// parseHeader reads "-- Description:", "-- Note:" and "-- Created:" lines
+// from the comment header at the top of a migration. Missing or malformed
+// fields are left zero.
+func parseHeader(r io.Reader) migrationHeader {
+	var header migrationHeader
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+
+		comment := strings.TrimSpace(strings.TrimPrefix(line, "--"))
+		key, value, found := strings.Cut(comment, ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "description":
+			if header.description == "" {
+				header.description = value
+			}
+		case "note":
+			if header.note == "" {
+				header.note = value
+			}
+		case "created":
+			if created, err := time.ParseInLocation(createdLayout, value, time.Local); err == nil && header.created.IsZero() {
+				header.created = created
+			}
+		}
+	}
+	return header
+}
@@ -0,0 +1,25 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// hasHooks reports whether opts has a before or after hook to run
+func hasHooks(opts UpOptions) bool {
+	return strings.TrimSpace(opts.BeforeHook) != "" || strings.TrimSpace(opts.AfterHook) != ""
+}
+
+// runHook executes the SQL of the named up hook outside any transaction.
+// An empty hook does nothing.
+func (e *Engine) runHook(ctx context.Context, name, sql string) error {
+	if strings.TrimSpace(sql) == "" {
+		return nil
+	}
+	if err := e.storage.ExecuteSQLNoTxContext(ctx, sql); err != nil {
+		return newError(CodeMigrationFailed, "", err, "%s hook failed", name)
+	}
+	fmt.Fprintf(e.out, "Ran %s hook\n", name)
+	return nil
+}
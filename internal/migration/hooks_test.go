@@ -0,0 +1,86 @@
+package migration
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rubenmeza/turso-migrate/internal/storage"
+)
+
+// openTempStorage opens a storage on a new SQLite file in a temporary
+// directory
+func openTempStorage(t *testing.T) *storage.TursoStorage {
+	t.Helper()
+	s, err := storage.New("file:"+filepath.Join(t.TempDir(), "test.db"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// hookMigrations are migrations logging themselves to the temporary table
+// hook_log, which only exists on the connection the before hook ran on
+var hookMigrations = map[string]string{
+	"001_first.sql":  "-- ==== UP ====\nCREATE TABLE hook_runs (logged INTEGER);\nINSERT INTO hook_log VALUES ('001');\n",
+	"002_second.sql": "-- ==== UP ====\nINSERT INTO hook_log VALUES ('002');\n",
+}
+
+const (
+	beforeHook = "CREATE TEMP TABLE hook_log (version TEXT)"
+	afterHook  = "INSERT INTO main.hook_runs SELECT COUNT(*) FROM hook_log"
+)
+
+// hookRuns returns the rows the after hook inserted
+func hookRuns(t *testing.T, s *storage.TursoStorage) [][]any {
+	t.Helper()
+	_, rows, err := s.DumpRows("hook_runs", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestHooksShareConnectionWithMigrations(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, hookMigrations)
+
+	s := openTempStorage(t)
+	e := NewEngine(s, dir)
+	e.SetOutput(io.Discard)
+
+	err := e.UpContext(context.Background(), UpOptions{Concurrency: 4, BeforeHook: beforeHook, AfterHook: afterHook})
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if rows := hookRuns(t, s); len(rows) != 1 || rows[0][0] != int64(2) {
+		t.Errorf("after hook logged %v, want one run of 2 migrations", rows)
+	}
+}
+
+func TestAfterHookRunsWhenCancelled(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, hookMigrations)
+
+	s := openTempStorage(t)
+	e := NewEngine(s, dir)
+	e.SetOutput(io.Discard)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.SetStatementLogger(func(stmt string) {
+		if strings.Contains(stmt, "'002'") {
+			cancel()
+		}
+	})
+
+	if err := e.UpContext(ctx, UpOptions{BeforeHook: beforeHook, AfterHook: afterHook}); err == nil {
+		t.Fatal("Up succeeded after being cancelled")
+	}
+	if rows := hookRuns(t, s); len(rows) != 1 || rows[0][0] != int64(1) {
+		t.Errorf("after hook logged %v, want one run of 1 migration", rows)
+	}
+}
@@ -0,0 +1,128 @@
+package migration
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Interactive runs a line-based session for viewing migration status,
+// previewing SQL and applying pending migrations, reading one command per
+// line from in. The session is written to the prompt writer, so --quiet
+// never hides what is being confirmed
+func (e *Engine) Interactive(in io.Reader) error {
+	reader := bufio.NewReader(in)
+
+	for {
+		files, appliedSet, err := e.loadState()
+		if err != nil {
+			return err
+		}
+
+		e.printInteractiveList(files, appliedSet)
+
+		fmt.Fprint(e.prompt, "> ")
+		input, err := reader.ReadString('\n')
+		if err != nil && input == "" {
+			if err == io.EOF {
+				fmt.Fprintln(e.prompt)
+				return nil
+			}
+			return err
+		}
+		input = strings.TrimSpace(input)
+
+		switch input {
+		case "", "r":
+			continue
+		case "q":
+			return nil
+		case "a":
+			pending := countPending(files, appliedSet)
+			if pending == 0 {
+				fmt.Fprintln(e.prompt, "No pending migrations")
+				continue
+			}
+
+			ok, err := Confirm(reader, e.prompt, fmt.Sprintf("Apply %d pending migration(s)?", pending), "")
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fmt.Fprintln(e.prompt, "Cancelled")
+				continue
+			}
+
+			if err := e.Up(UpOptions{}); err != nil {
+				fmt.Fprintf(e.prompt, "Error: %v\n", err)
+			}
+		default:
+			n, err := strconv.Atoi(input)
+			if err != nil || n < 1 || n > len(files) {
+				fmt.Fprintf(e.prompt, "Unknown command: %s\n", input)
+				continue
+			}
+
+			file := files[n-1]
+			fmt.Fprintf(e.prompt, "\n-- %s_%s (UP)\n%s\n", file.Version, file.Name, file.UpSQL)
+			if file.DownSQL != "" {
+				fmt.Fprintf(e.prompt, "\n-- %s_%s (DOWN)\n%s\n", file.Version, file.Name, file.DownSQL)
+			}
+			fmt.Fprintln(e.prompt)
+		}
+	}
+}
+
+// loadState loads migration files and the set of applied versions
+func (e *Engine) loadState() ([]MigrationFile, map[string]bool, error) {
+	files, err := e.loadMigrationFiles()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load migration files: %w", err)
+	}
+
+	applied, err := e.storage.GetAppliedMigrations()
+	if err != nil {
+		return nil, nil, newError(CodeStorageFailed, "", err, "failed to get applied migrations")
+	}
+
+	appliedSet := make(map[string]bool)
+	for _, m := range applied {
+		appliedSet[m.Version] = true
+	}
+
+	return files, appliedSet, nil
+}
+
+// printInteractiveList prints the numbered migration list and command help
+func (e *Engine) printInteractiveList(files []MigrationFile, appliedSet map[string]bool) {
+	fmt.Fprintln(e.prompt, "Migrations:")
+	fmt.Fprintln(e.prompt, "===========")
+
+	if len(files) == 0 {
+		fmt.Fprintln(e.prompt, "No migrations found")
+	}
+
+	for i, file := range files {
+		marker := e.paint(colorYellow, "✗")
+		if appliedSet[file.Version] {
+			marker = e.paint(colorGreen, "✓")
+		}
+		fmt.Fprintf(e.prompt, "%3d  %s %s_%s\n", i+1, marker, file.Version, file.Name)
+	}
+
+	fmt.Fprintln(e.prompt)
+	fmt.Fprintln(e.prompt, "Commands: <number> preview SQL, a apply pending, r refresh, q quit")
+}
+
+// countPending returns the number of files not in appliedSet
+func countPending(files []MigrationFile, appliedSet map[string]bool) int {
+	var n int
+	for _, file := range files {
+		if !appliedSet[file.Version] {
+			n++
+		}
+	}
+	return n
+}
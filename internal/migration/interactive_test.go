@@ -0,0 +1,40 @@
+package migration
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestInteractiveWritesToPromptOutput(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"001_users.sql": "-- ==== UP ====\nCREATE TABLE users (id INTEGER);\n",
+	})
+
+	s := openTempStorage(t)
+	e := NewEngine(s, dir)
+	e.SetOutput(io.Discard)
+	var prompt bytes.Buffer
+	e.SetPromptOutput(&prompt)
+
+	if err := e.Interactive(strings.NewReader("a\ny\nq\n")); err != nil {
+		t.Fatalf("Interactive: %v", err)
+	}
+
+	out := prompt.String()
+	for _, want := range []string{"001_users", "Apply 1 pending migration(s)?", "> "} {
+		if !strings.Contains(out, want) {
+			t.Errorf("prompt output is missing %q:\n%s", want, out)
+		}
+	}
+
+	applied, err := s.GetAppliedMigrations()
+	if err != nil {
+		t.Fatalf("GetAppliedMigrations: %v", err)
+	}
+	if len(applied) != 1 {
+		t.Errorf("applied %d migration(s), want 1", len(applied))
+	}
+}
@@ -0,0 +1,69 @@
+package migration
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+)
+
+// ListFilesOptions controls how ListFiles prints migration files
+type ListFilesOptions struct {
+	// JSON prints a JSON array instead of a table
+	JSON bool
+}
+
+// fileEntry describes a migration file as the loader sees it
+type fileEntry struct {
+	Version  string `json:"version"`
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	HasDown  bool   `json:"has_down"`
+	Checksum string `json:"checksum"`
+}
+
+// ListFiles prints every migration file the loader finds with its version,
+// name, path, whether it has a DOWN section and its SHA-256 checksum. It
+// reads only the migrations directory, never the database.
+func (e *Engine) ListFiles(opts ListFilesOptions) error {
+	files, err := e.loadMigrationFiles()
+	if err != nil {
+		return fmt.Errorf("failed to load migration files: %w", err)
+	}
+
+	entries := []fileEntry{}
+	for _, file := range files {
+		sum, err := fileChecksum(e.fsys, file.fsName)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, fileEntry{
+			Version:  file.Version,
+			Name:     file.Name,
+			Path:     file.Path,
+			HasDown:  file.DownSQL != "",
+			Checksum: sum,
+		})
+	}
+
+	if opts.JSON {
+		enc := json.NewEncoder(e.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+
+	if len(entries) == 0 {
+		fmt.Fprintln(e.out, "No migrations found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(e.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tDOWN\tCHECKSUM\tPATH")
+	for _, entry := range entries {
+		down := "no"
+		if entry.HasDown {
+			down = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", entry.Version, entry.Name, down, entry.Checksum[:12], entry.Path)
+	}
+	return w.Flush()
+}
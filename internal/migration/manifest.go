@@ -0,0 +1,142 @@
+package migration
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// DefaultManifestFile is the manifest's file name within the migrations directory
+const DefaultManifestFile = "manifest.json"
+
+// manifest lists migration files with their checksums
+type manifest struct {
+	Migrations []manifestEntry `json:"migrations"`
+}
+
+// manifestEntry describes a single migration file in a manifest
+type manifestEntry struct {
+	Version string `json:"version"`
+	Name    string `json:"name"`
+	File    string `json:"file"`
+	SHA256  string `json:"sha256"`
+}
+
+// GenerateManifest writes a manifest of every migration file with its
+// version, name and SHA-256 checksum to path
+func (e *Engine) GenerateManifest(path string) error {
+	if err := e.checkWritable("write the manifest"); err != nil {
+		return err
+	}
+
+	m, err := e.buildManifest()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	fmt.Fprintf(e.out, "Wrote manifest of %d migration(s) to %s\n", len(m.Migrations), path)
+	return nil
+}
+
+// VerifyManifest compares the migration files with the manifest at path,
+// reporting every file added, removed or changed since it was generated
+func (e *Engine) VerifyManifest(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var expected manifest
+	if err := json.Unmarshal(data, &expected); err != nil {
+		return fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+
+	actual, err := e.buildManifest()
+	if err != nil {
+		return err
+	}
+
+	current := make(map[string]manifestEntry)
+	for _, entry := range actual.Migrations {
+		current[entry.File] = entry
+	}
+
+	var issues int
+	listed := make(map[string]bool)
+	for _, entry := range expected.Migrations {
+		listed[entry.File] = true
+		got, ok := current[entry.File]
+		switch {
+		case !ok:
+			fmt.Fprintf(e.out, "%s %s: removed\n", e.paint(colorRed, "✗"), entry.File)
+			issues++
+		case got.SHA256 != entry.SHA256:
+			fmt.Fprintf(e.out, "%s %s: changed\n", e.paint(colorRed, "✗"), entry.File)
+			issues++
+		}
+	}
+	for _, entry := range actual.Migrations {
+		if !listed[entry.File] {
+			fmt.Fprintf(e.out, "%s %s: not in manifest\n", e.paint(colorRed, "✗"), entry.File)
+			issues++
+		}
+	}
+
+	if issues > 0 {
+		return newError(CodeManifestMismatch, "", nil, "%d migration file(s) differ from manifest %s", issues, path)
+	}
+
+	fmt.Fprintf(e.out, "All %d migration(s) match the manifest\n", len(actual.Migrations))
+	return nil
+}
+
+// buildManifest describes the current migration files
+func (e *Engine) buildManifest() (*manifest, error) {
+	files, err := e.loadMigrationFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migration files: %w", err)
+	}
+
+	m := &manifest{Migrations: []manifestEntry{}}
+	for _, file := range files {
+		sum, err := fileChecksum(e.fsys, file.fsName)
+		if err != nil {
+			return nil, err
+		}
+
+		m.Migrations = append(m.Migrations, manifestEntry{
+			Version: file.Version,
+			Name:    file.Name,
+			File:    e.relPath(file.fsName),
+			SHA256:  sum,
+		})
+	}
+	return m, nil
+}
+
+// fileChecksum returns the hex-encoded SHA-256 of the file named name in fsys
+func fileChecksum(fsys fs.FS, name string) (string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
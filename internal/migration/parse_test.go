@@ -0,0 +1,60 @@
+package migration
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/rubenmeza/turso-migrate/internal/storage"
+)
+
+func TestParseAndSplitTriggers(t *testing.T) {
+	e := NewEngine(nil, "../../examples/migrations")
+	e.SetOutput(io.Discard)
+
+	file, err := e.parseMigrationFile("004_add_post_audit_log.sql")
+	if err != nil {
+		t.Fatalf("parseMigrationFile: %v", err)
+	}
+
+	up := storage.SplitStatements(file.UpSQL)
+	if len(up) != 3 {
+		t.Fatalf("UP split into %d statements, want 3:\n%s", len(up), strings.Join(up, "\n----\n"))
+	}
+	wantPrefixes := []string{
+		"CREATE TABLE post_audit_log",
+		"CREATE TRIGGER posts_audit_update",
+		"CREATE TRIGGER posts_audit_delete",
+	}
+	for i, prefix := range wantPrefixes {
+		stmt := stripComments(up[i])
+		if !strings.HasPrefix(stmt, prefix) {
+			t.Errorf("statement %d starts %q, want %q", i+1, firstLine(stmt), prefix)
+		}
+		if i > 0 && !strings.HasSuffix(strings.TrimRight(stmt, ";"), "END") {
+			t.Errorf("trigger %d was cut before its END:\n%s", i, stmt)
+		}
+	}
+	if !strings.Contains(up[2], "'published posts cannot be deleted; unpublish first'") {
+		t.Errorf("string literal with a semicolon was split:\n%s", up[2])
+	}
+
+	if down := storage.SplitStatements(file.DownSQL); len(down) != 3 {
+		t.Errorf("DOWN split into %d statements, want 3", len(down))
+	}
+}
+
+// stripComments drops the comment lines before a statement
+func stripComments(stmt string) string {
+	lines := strings.Split(stmt, "\n")
+	for len(lines) > 0 && (strings.HasPrefix(strings.TrimSpace(lines[0]), "--") || strings.TrimSpace(lines[0]) == "") {
+		lines = lines[1:]
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// firstLine returns the first line of s
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
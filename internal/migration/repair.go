@@ -0,0 +1,104 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/rubenmeza/turso-migrate/internal/storage"
+)
+
+// RepairOptions controls what Repair changes
+type RepairOptions struct {
+	// Prune deletes the records of applied migrations that have no file
+	Prune bool
+	// Input, if set, is where the answer is read from when asking to
+	// confirm the records to prune; nil prunes without asking
+	Input io.Reader
+	// Phrase, if set, must be typed to confirm instead of "y"
+	Phrase string
+}
+
+// Repair compares the migrations table with the files on disk after files
+// were renamed or deleted. It lists applied migrations whose file is gone,
+// which down can't roll back, and unrecorded files older than the newest
+// applied migration, often the new name of a renamed file. With Prune the
+// records without a file are deleted; files are never touched.
+func (e *Engine) Repair(opts RepairOptions) error {
+	if opts.Prune {
+		if err := e.checkWritable("prune migration records"); err != nil {
+			return err
+		}
+	}
+
+	files, err := e.loadMigrationFiles()
+	if err != nil {
+		return fmt.Errorf("failed to load migration files: %w", err)
+	}
+
+	ctx := context.Background()
+	if opts.Prune {
+		if err := e.lock(ctx); err != nil {
+			return err
+		}
+		defer e.unlock()
+	}
+
+	applied, err := e.storage.GetAppliedMigrationsContext(ctx)
+	if err != nil {
+		return newError(CodeStorageFailed, "", err, "failed to get applied migrations")
+	}
+
+	onDisk := make(map[string]bool, len(files))
+	for _, file := range files {
+		onDisk[file.Version] = true
+	}
+	var orphans []storage.Migration
+	for _, m := range applied {
+		if !onDisk[m.Version] {
+			orphans = append(orphans, m)
+		}
+	}
+	late := outOfOrder(files, applied)
+
+	if len(orphans) == 0 && len(late) == 0 {
+		fmt.Fprintln(e.out, "Migrations table and files are in sync")
+		return nil
+	}
+
+	for _, m := range orphans {
+		fmt.Fprintf(e.out, "%s %s_%s: recorded as applied, but no file was found\n", e.paint(colorRed, "✗"), m.Version, m.Name)
+	}
+	for _, file := range files {
+		if late[file.Version] {
+			fmt.Fprintf(e.out, "%s %s_%s: not recorded, but older than the newest applied migration\n", e.paint(colorYellow, "✗"), file.Version, file.Name)
+		}
+	}
+
+	if len(orphans) == 0 {
+		return nil
+	}
+	if !opts.Prune {
+		fmt.Fprintf(e.out, "Run repair --prune to delete the %d record(s) without a file\n", len(orphans))
+		return nil
+	}
+
+	if opts.Input != nil {
+		var items []string
+		for _, m := range orphans {
+			items = append(items, m.Version+" "+m.Name)
+		}
+		if err := e.confirmPlan(opts.Input, opts.Phrase, "Records to delete:", items, "repair cancelled; no records were deleted"); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range orphans {
+		if err := e.storage.RemoveMigrationContext(ctx, m.Version); err != nil {
+			return newError(CodeRecordFailed, m.Version, err, "failed to delete the record of migration %s", m.Version)
+		}
+		fmt.Fprintf(e.out, "Pruned record of migration %s: %s\n", m.Version, m.Name)
+	}
+	fmt.Fprintf(e.out, "Pruned %d record(s); the schema changes they made were left in place\n", len(orphans))
+	return nil
+}
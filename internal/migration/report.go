@@ -0,0 +1,101 @@
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// AppliedMigration describes one migration applied during an up run
+type AppliedMigration struct {
+	Version  string
+	Name     string
+	Duration time.Duration
+}
+
+// runLog collects the migrations applied during a run; it is safe for
+// concurrent use
+type runLog struct {
+	mu      sync.Mutex
+	applied []AppliedMigration
+}
+
+// add records that file was applied in d
+func (l *runLog) add(file MigrationFile, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.applied = append(l.applied, AppliedMigration{Version: file.Version, Name: file.Name, Duration: d})
+}
+
+// UpApplied runs UpContext and returns the migrations it applied, in the
+// order they finished. On error it returns those applied before the
+// failure, except after a failed atomic run, which keeps none.
+func (e *Engine) UpApplied(ctx context.Context, opts UpOptions) ([]AppliedMigration, error) {
+	log := &runLog{applied: []AppliedMigration{}}
+	e.report = log
+	defer func() { e.report = nil }()
+
+	opts.JSON = false
+	err := e.UpContext(ctx, opts)
+	if err != nil && opts.Atomic {
+		return []AppliedMigration{}, err
+	}
+	return log.applied, err
+}
+
+// runReport is the JSON summary of an up run
+type runReport struct {
+	Applied       []appliedReport `json:"applied"`
+	Count         int             `json:"count"`
+	DurationMs    int64           `json:"duration_ms"`
+	Success       bool            `json:"success"`
+	FailedVersion string          `json:"failed_version,omitempty"`
+	Error         string          `json:"error,omitempty"`
+}
+
+// appliedReport describes one migration applied during a run
+type appliedReport struct {
+	Version    string `json:"version"`
+	Name       string `json:"name"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// upJSON runs Up with its usual output discarded and prints a JSON summary
+// of the run instead, whether it succeeds or not
+func (e *Engine) upJSON(ctx context.Context, opts UpOptions) error {
+	out := e.out
+	e.out = io.Discard
+	defer func() { e.out = out }()
+
+	start := time.Now()
+	applied, err := e.UpApplied(ctx, opts)
+
+	report := &runReport{Applied: []appliedReport{}}
+	for _, m := range applied {
+		report.Applied = append(report.Applied, appliedReport{
+			Version:    m.Version,
+			Name:       m.Name,
+			DurationMs: m.Duration.Milliseconds(),
+		})
+	}
+	report.Count = len(report.Applied)
+	report.DurationMs = time.Since(start).Milliseconds()
+	report.Success = err == nil
+	if err != nil {
+		report.Error = err.Error()
+		var merr *Error
+		if errors.As(err, &merr) {
+			report.FailedVersion = merr.Version
+		}
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if encErr := enc.Encode(report); encErr != nil && err == nil {
+		return encErr
+	}
+	return err
+}
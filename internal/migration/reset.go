@@ -0,0 +1,95 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// ResetOptions controls how Reset removes migration state
+type ResetOptions struct {
+	// Hard drops the migrations table without rolling anything back,
+	// leaving the schema in place
+	Hard bool
+	// Input, if set, is where the answer is read from when asking to
+	// confirm the reset; nil resets without asking
+	Input io.Reader
+	// Phrase must be typed to confirm
+	Phrase string
+}
+
+// Reset removes all migration state from the database, for tearing down
+// test databases. It rolls back every applied migration, newest first,
+// then drops the migrations table; nothing runs if any migration has no
+// DOWN section. With Hard only the table is dropped.
+func (e *Engine) Reset(opts ResetOptions) error {
+	return e.ResetContext(context.Background(), opts)
+}
+
+// ResetContext is Reset with a context. Once ctx is cancelled no further
+// migration is rolled back and the migrations table is kept.
+func (e *Engine) ResetContext(ctx context.Context, opts ResetOptions) error {
+	if err := e.checkWritable("reset migrations"); err != nil {
+		return err
+	}
+
+	files, err := e.loadMigrationFiles()
+	if err != nil {
+		return fmt.Errorf("failed to load migration files: %w", err)
+	}
+
+	if err := e.lock(ctx); err != nil {
+		return err
+	}
+	defer e.unlock()
+
+	applied, err := e.storage.GetAppliedMigrationsContext(ctx)
+	if err != nil {
+		return newError(CodeStorageFailed, "", err, "failed to get applied migrations")
+	}
+
+	var plan []MigrationFile
+	if !opts.Hard {
+		for i := len(applied) - 1; i >= 0; i-- {
+			file, err := findDownFile(files, applied[i], "")
+			if err != nil {
+				return err
+			}
+			if file.DownSQL == "" {
+				return newError(CodeMissingDown, file.Version, nil, "cannot reset: %s; use --hard to only drop the migrations table", missingDown(*file))
+			}
+			plan = append(plan, *file)
+		}
+	}
+
+	if opts.Input != nil {
+		var items []string
+		for _, file := range plan {
+			items = append(items, "roll back "+file.Version+" "+file.Name)
+		}
+		items = append(items, fmt.Sprintf("drop the migrations table and its %d record(s)", len(applied)))
+		if err := e.confirmPlan(opts.Input, opts.Phrase, e.paint(colorRed, "WARNING:")+" this will remove all migration state:", items, "reset cancelled; nothing was changed"); err != nil {
+			return err
+		}
+	}
+
+	for i, file := range plan {
+		if err := e.cancelled(ctx, file.Version, i, len(plan)-i); err != nil {
+			return err
+		}
+		if err := e.rollback(ctx, file, false); err != nil {
+			return err
+		}
+	}
+
+	if err := e.storage.DropTrackingTableContext(ctx); err != nil {
+		return newError(CodeStorageFailed, "", err, "failed to drop the migrations table")
+	}
+
+	if opts.Hard {
+		fmt.Fprintf(e.out, "Dropped the migrations table; the schema of %d applied migration(s) was left in place\n", len(applied))
+	} else {
+		fmt.Fprintf(e.out, "Rolled back %d migration(s) and dropped the migrations table\n", len(plan))
+	}
+	return nil
+}
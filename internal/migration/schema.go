@@ -0,0 +1,93 @@
+package migration
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/rubenmeza/turso-migrate/internal/storage"
+)
+
+var (
+	whitespacePattern  = regexp.MustCompile(`\s+`)
+	punctuationPattern = regexp.MustCompile(`\s*([(),])\s*`)
+	ifNotExistsPattern = regexp.MustCompile(`^(create (?:temp |temporary |unique |virtual )?(?:table|index|view|trigger)) if not exists `)
+)
+
+// AssertSchema compares the live database schema with the statements in
+// the file at path, printing a diff and failing if they differ
+func (e *Engine) AssertSchema(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read expected schema: %w", err)
+	}
+
+	live, err := e.storage.DumpSchema()
+	if err != nil {
+		return newError(CodeStorageFailed, "", err, "failed to dump schema")
+	}
+
+	expected := normalizeSchema(storage.SplitStatements(string(content)))
+	actual := normalizeSchema(live)
+
+	missing := difference(expected, actual)
+	extra := difference(actual, expected)
+	if len(missing) == 0 && len(extra) == 0 {
+		fmt.Fprintln(e.out, "Schema matches", path)
+		return nil
+	}
+
+	fmt.Fprintf(e.out, "Schema does not match %s:\n", path)
+	for _, stmt := range missing {
+		fmt.Fprintf(e.out, "- %s\n", stmt)
+	}
+	for _, stmt := range extra {
+		fmt.Fprintf(e.out, "+ %s\n", stmt)
+	}
+
+	return newError(CodeSchemaMismatch, "", nil, "schema differs from %s (%d missing, %d unexpected)", path, len(missing), len(extra))
+}
+
+// normalizeSchema normalizes case, whitespace and comments of each
+// statement and sorts them so that ordering doesn't affect comparison
+func normalizeSchema(statements []string) []string {
+	var normalized []string
+	for _, stmt := range statements {
+		var lines []string
+		for _, line := range strings.Split(stmt, "\n") {
+			if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+				lines = append(lines, line)
+			}
+		}
+
+		s := strings.ToLower(strings.Join(lines, " "))
+		s = whitespacePattern.ReplaceAllString(s, " ")
+		s = punctuationPattern.ReplaceAllString(s, "$1")
+		s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), ";"))
+		s = ifNotExistsPattern.ReplaceAllString(s, "$1 ")
+		if s != "" {
+			normalized = append(normalized, s)
+		}
+	}
+
+	sort.Strings(normalized)
+	return normalized
+}
+
+// difference returns the elements of a that are not in b
+func difference(a, b []string) []string {
+	seen := make(map[string]bool, len(b))
+	for _, s := range b {
+		seen[s] = true
+	}
+
+	var diff []string
+	for _, s := range a {
+		if !seen[s] {
+			diff = append(diff, s)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,38 @@
+package migration
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// ShardOf returns the 1-based shard, out of shards, that version hashes into
+func ShardOf(version string, shards int) int {
+	h := fnv.New32a()
+	h.Write([]byte(version))
+	return int(h.Sum32()%uint32(shards)) + 1
+}
+
+// ParseShard parses a shard specification such as "1/4" into its shard
+// number and total number of shards
+func ParseShard(spec string) (shard, shards int, err error) {
+	before, after, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, fmt.Errorf("invalid shard %q: expected <shard>/<total>", spec)
+	}
+
+	shard, err = strconv.Atoi(strings.TrimSpace(before))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid shard %q: %w", spec, err)
+	}
+	shards, err = strconv.Atoi(strings.TrimSpace(after))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid shard %q: %w", spec, err)
+	}
+
+	if shards < 1 || shard < 1 || shard > shards {
+		return 0, 0, fmt.Errorf("invalid shard %q: shard must be between 1 and the total", spec)
+	}
+	return shard, shards, nil
+}
@@ -0,0 +1,79 @@
+package migration
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestShardOf(t *testing.T) {
+	// The buckets must never change between releases, or workers running
+	// different versions would apply overlapping subsets
+	tests := []struct {
+		version string
+		shards  int
+		want    int
+	}{
+		{"001", 4, 1},
+		{"002", 4, 2},
+		{"003", 4, 3},
+		{"004", 4, 4},
+		{"003", 3, 2},
+		{"20240115093000", 4, 1},
+		{"20240115093000", 3, 3},
+		{"001", 1, 1},
+	}
+	for _, tt := range tests {
+		if got := ShardOf(tt.version, tt.shards); got != tt.want {
+			t.Errorf("ShardOf(%q, %d) = %d, want %d", tt.version, tt.shards, got, tt.want)
+		}
+	}
+}
+
+func TestShardOfDistribution(t *testing.T) {
+	const shards = 4
+	counts := make(map[int]int)
+	for i := 1; i <= 400; i++ {
+		shard := ShardOf(fmt.Sprintf("%03d", i), shards)
+		if shard < 1 || shard > shards {
+			t.Fatalf("ShardOf(%03d, %d) = %d, out of range", i, shards, shard)
+		}
+		counts[shard]++
+	}
+	for shard := 1; shard <= shards; shard++ {
+		if counts[shard] < 50 {
+			t.Errorf("shard %d got %d of 400 versions, want a fair share", shard, counts[shard])
+		}
+	}
+}
+
+func TestParseShard(t *testing.T) {
+	tests := []struct {
+		spec          string
+		shard, shards int
+		wantErr       bool
+	}{
+		{spec: "1/4", shard: 1, shards: 4},
+		{spec: "4/4", shard: 4, shards: 4},
+		{spec: " 2 / 3 ", shard: 2, shards: 3},
+		{spec: "0/3", wantErr: true},
+		{spec: "4/3", wantErr: true},
+		{spec: "1/0", wantErr: true},
+		{spec: "-1/3", wantErr: true},
+		{spec: "a/b", wantErr: true},
+		{spec: "1/b", wantErr: true},
+		{spec: "3", wantErr: true},
+		{spec: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		shard, shards, err := ParseShard(tt.spec)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseShard(%q) = %d, %d; want an error", tt.spec, shard, shards)
+			}
+			continue
+		}
+		if err != nil || shard != tt.shard || shards != tt.shards {
+			t.Errorf("ParseShard(%q) = %d, %d, %v; want %d, %d", tt.spec, shard, shards, err, tt.shard, tt.shards)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package migration
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SnapshotOptions controls how Snapshot generates a seed data migration
+type SnapshotOptions struct {
+	// Tables are the tables whose rows are captured
+	Tables []string
+	// MaxRows is the most rows captured from any one table
+	MaxRows int
+	// DryRun prints the path and content instead of writing the file
+	DryRun bool
+}
+
+// Snapshot creates a migration whose UP section inserts the current rows of
+// the given tables and whose DOWN section deletes them
+func (e *Engine) Snapshot(name string, opts SnapshotOptions) error {
+	if len(opts.Tables) == 0 {
+		return fmt.Errorf("at least one table is required")
+	}
+
+	quote := e.storage.Dialect().QuoteIdentifier
+	var up, down strings.Builder
+	for _, table := range opts.Tables {
+		columns, rows, err := e.storage.DumpRows(table, opts.MaxRows)
+		if err != nil {
+			return newError(CodeStorageFailed, "", err, "failed to read rows of %s", table)
+		}
+
+		quoted := make([]string, len(columns))
+		for i, column := range columns {
+			quoted[i] = quote(column)
+		}
+
+		fmt.Fprintf(&up, "-- %s: %d row(s)\n", table, len(rows))
+		for _, row := range rows {
+			values := make([]string, len(row))
+			for i, value := range row {
+				values[i] = sqlLiteral(value)
+			}
+			fmt.Fprintf(&up, "INSERT OR REPLACE INTO %s (%s) VALUES (%s);\n",
+				quote(table), strings.Join(quoted, ", "), strings.Join(values, ", "))
+		}
+		up.WriteString("\n")
+
+		fmt.Fprintf(&down, "DELETE FROM %s;\n", quote(table))
+	}
+
+	content := fmt.Sprintf("-- Migration: %s\n-- Created: %s\n-- Snapshot of: %s\n\n",
+		name, time.Now().Format("2006-01-02 15:04:05"), strings.Join(opts.Tables, ", "))
+	if e.upOnly {
+		content += up.String()
+	} else {
+		content += fmt.Sprintf("-- %s\n%s\n-- %s\n%s", e.markers.up, up.String(), e.markers.down, down.String())
+	}
+
+	return e.writeMigration(name, content, "", opts.DryRun)
+}
+
+// sqlLiteral formats a value read from the database as a SQL literal
+func sqlLiteral(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case []byte:
+		return "X'" + hex.EncodeToString(v) + "'"
+	case time.Time:
+		return quoteString(v.Format("2006-01-02 15:04:05"))
+	case string:
+		return quoteString(v)
+	default:
+		return quoteString(fmt.Sprint(v))
+	}
+}
+
+// quoteString quotes s as a SQL string literal
+func quoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
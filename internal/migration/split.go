@@ -0,0 +1,111 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// Suffixes of the two files of a migration split into separate UP and DOWN
+// files, such as 001_add_users.up.sql and 001_add_users.down.sql
+const (
+	upSuffix   = ".up.sql"
+	downSuffix = ".down.sql"
+)
+
+// isDownFile reports whether name is the DOWN half of a split migration,
+// which is read along with its UP file rather than on its own
+func isDownFile(name string) bool {
+	return strings.HasSuffix(name, downSuffix)
+}
+
+// downFileName returns the name of the DOWN file paired with the UP file
+// name
+func downFileName(name string) string {
+	return strings.TrimSuffix(name, upSuffix) + downSuffix
+}
+
+// orphanDownFiles returns the names of DOWN files that have no matching UP
+// file, which are never read
+func (e *Engine) orphanDownFiles() ([]string, error) {
+	var orphans []string
+	err := fs.WalkDir(e.fsys, e.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !isDownFile(path) {
+			return err
+		}
+		up := strings.TrimSuffix(path, downSuffix) + upSuffix
+		if _, err := fs.Stat(e.fsys, up); errors.Is(err, fs.ErrNotExist) {
+			orphans = append(orphans, path)
+		}
+		return nil
+	})
+	return orphans, err
+}
+
+// parseSplitMigration parses the split migration whose UP file is named
+// name in the engine's file system. The whole UP file is the UP section
+// and the whole DOWN file, if any, the DOWN section; a migration without a
+// DOWN file is irreversible.
+func (e *Engine) parseSplitMigration(name, version, migrationName string) (*MigrationFile, error) {
+	file := &MigrationFile{
+		Version: version,
+		Name:    migrationName,
+		Path:    e.displayPath(name),
+		fsName:  name,
+	}
+
+	var directives map[string]bool
+	if e.streaming {
+		var err error
+		if _, directives, err = parseMigrationStream(e.fsys, name, sectionMarkers{}); err != nil {
+			return nil, err
+		}
+		if file.UpNoTxn, err = e.upNoTxn(name, nil); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := e.checkSize(name, version); err != nil {
+			return nil, err
+		}
+		content, err := fs.ReadFile(e.fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		file.UpSQL = strings.TrimSpace(string(content))
+		file.UpNoTxn = leadingDirective(strings.NewReader(file.UpSQL), "txn:none")
+		directives = make(map[string]bool)
+		for _, directive := range []string{"migrate:manual", "migrate:independent"} {
+			directives[directive] = hasDirective(file.UpSQL, directive)
+		}
+	}
+	file.Manual = directives["migrate:manual"]
+	file.Independent = directives["migrate:independent"]
+
+	header, err := readHeader(e.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	file.Description, file.Note, file.Created = header.description, header.note, header.created
+
+	if e.upOnly {
+		return file, nil
+	}
+
+	// The DOWN file is read fully into memory even when streaming
+	downName := downFileName(name)
+	if err := e.checkSize(downName, version); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	down, err := fs.ReadFile(e.fsys, downName)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		file.Irreversible = true
+	case err != nil:
+		return nil, fmt.Errorf("failed to read DOWN file: %w", err)
+	default:
+		file.DownSQL = strings.TrimSpace(string(down))
+		file.DownNoTxn = leadingDirective(strings.NewReader(file.DownSQL), "txn:none")
+	}
+	return file, nil
+}
@@ -0,0 +1,70 @@
+package migration
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSplitMigrationFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"001_users.sql":         "-- ==== UP ====\nCREATE TABLE users (id INTEGER);\n-- ==== DOWN ====\nDROP TABLE users;\n",
+		"002_posts.up.sql":      "CREATE TABLE posts (id INTEGER);\n",
+		"002_posts.down.sql":    "DROP TABLE posts;\n",
+		"003_backfill.up.sql":   "UPDATE users SET id = id;\n",
+		"004_comments.down.sql": "DROP TABLE comments;\n",
+	})
+
+	e := NewEngine(nil, dir)
+	e.SetOutput(io.Discard)
+
+	files, err := e.loadMigrationFiles()
+	if err != nil {
+		t.Fatalf("loadMigrationFiles: %v", err)
+	}
+	want := []struct {
+		version, up, down string
+		irreversible      bool
+	}{
+		{"001", "CREATE TABLE users (id INTEGER);", "DROP TABLE users;", false},
+		{"002", "CREATE TABLE posts (id INTEGER);", "DROP TABLE posts;", false},
+		{"003", "UPDATE users SET id = id;", "", true},
+	}
+	if len(files) != len(want) {
+		t.Fatalf("loaded %d file(s), want %d: %+v", len(files), len(want), files)
+	}
+	for i, w := range want {
+		f := files[i]
+		if f.Version != w.version || f.UpSQL != w.up || f.DownSQL != w.down || f.Irreversible != w.irreversible {
+			t.Errorf("file %d = %s up %q down %q irreversible %v, want %s up %q down %q irreversible %v",
+				i, f.Version, f.UpSQL, f.DownSQL, f.Irreversible, w.version, w.up, w.down, w.irreversible)
+		}
+	}
+
+	var out bytes.Buffer
+	e.SetOutput(&out)
+	if err := e.Validate(ValidateOptions{}); err == nil {
+		t.Error("Validate accepted a DOWN file without an UP file")
+	}
+	if !strings.Contains(out.String(), "004_comments.down.sql") {
+		t.Errorf("Validate output = %q, want the orphan DOWN file", out.String())
+	}
+}
+
+func TestSplitMigrationDownFileSize(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"001_users.up.sql":   "CREATE TABLE users (id INTEGER);\n",
+		"001_users.down.sql": "-- " + strings.Repeat("x", 100) + "\nDROP TABLE users;\n",
+	})
+
+	e := NewEngine(nil, dir)
+	e.SetOutput(io.Discard)
+	e.SetMaxMigrationSize(64)
+
+	if _, err := e.loadMigrationFiles(); err == nil || !strings.Contains(err.Error(), "001_users.down.sql") {
+		t.Errorf("loadMigrationFiles() error = %v, want the DOWN file over the size limit", err)
+	}
+}
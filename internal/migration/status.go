@@ -0,0 +1,169 @@
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rubenmeza/turso-migrate/internal/storage"
+)
+
+// MigrationStatus is the status of one migration file, as printed by
+// status --json
+type MigrationStatus struct {
+	Version   string     `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at"`
+	// OutOfOrder marks a pending migration older than the newest applied one
+	OutOfOrder bool `json:"out_of_order"`
+	// DurationMs is how long an applied migration took to execute, omitted
+	// when it wasn't recorded
+	DurationMs int64 `json:"duration_ms,omitempty"`
+	// Note is the note recorded when the migration was applied, or that
+	// of its file, omitted when there is none
+	Note string `json:"note,omitempty"`
+}
+
+// Applied returns the applied migrations in version order, without
+// printing anything
+func (e *Engine) Applied() ([]storage.Migration, error) {
+	return e.applied(context.Background())
+}
+
+// applied returns the applied migrations in version order
+func (e *Engine) applied(ctx context.Context) ([]storage.Migration, error) {
+	applied, err := e.storage.GetAppliedMigrationsContext(ctx)
+	if err != nil {
+		return nil, newError(CodeStorageFailed, "", err, "failed to get applied migrations")
+	}
+	return applied, nil
+}
+
+// Pending returns the migration files that have not been applied, in
+// version order, without printing anything
+func (e *Engine) Pending() ([]MigrationFile, error) {
+	files, applied, err := e.migrationState(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	appliedSet := make(map[string]bool)
+	for _, m := range applied {
+		appliedSet[m.Version] = true
+	}
+
+	pending := []MigrationFile{}
+	for _, file := range files {
+		if !appliedSet[file.Version] {
+			pending = append(pending, file)
+		}
+	}
+	return pending, nil
+}
+
+// migrationState returns every migration file and the applied migrations
+func (e *Engine) migrationState(ctx context.Context) ([]MigrationFile, []storage.Migration, error) {
+	files, err := e.loadMigrationFiles()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load migration files: %w", err)
+	}
+
+	applied, err := e.applied(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return files, applied, nil
+}
+
+// Statuses returns the status of every migration file in version order
+func (e *Engine) Statuses(ctx context.Context) ([]MigrationStatus, error) {
+	files, applied, err := e.migrationState(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	appliedSet := make(map[string]storage.Migration)
+	for _, m := range applied {
+		appliedSet[m.Version] = m
+	}
+	return migrationStatuses(files, appliedSet, outOfOrder(files, applied)), nil
+}
+
+// printStatusJSON prints the status of every migration file as a JSON array
+func (e *Engine) printStatusJSON(files []MigrationFile, appliedSet map[string]storage.Migration, late map[string]bool) error {
+	enc := json.NewEncoder(e.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(migrationStatuses(files, appliedSet, late))
+}
+
+// migrationStatuses returns the status of each file given the applied
+// migrations and the versions that are out of order
+func migrationStatuses(files []MigrationFile, appliedSet map[string]storage.Migration, late map[string]bool) []MigrationStatus {
+	statuses := []MigrationStatus{}
+	for _, file := range files {
+		status := MigrationStatus{Version: file.Version, Name: file.Name, OutOfOrder: late[file.Version], Note: file.Note}
+		if m, ok := appliedSet[file.Version]; ok {
+			appliedAt := m.AppliedAt.UTC()
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+			status.DurationMs = m.Duration.Milliseconds()
+			if m.Note != "" {
+				status.Note = m.Note
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// statusSummary describes the number of applied and pending migrations
+// and the current version in one line, such as "3 applied, 2 pending,
+// current version 005"
+func statusSummary(files []MigrationFile, applied []storage.Migration, late map[string]bool) string {
+	appliedSet := make(map[string]bool)
+	for _, m := range applied {
+		appliedSet[m.Version] = true
+	}
+	var pending int
+	for _, file := range files {
+		if !appliedSet[file.Version] {
+			pending++
+		}
+	}
+
+	summary := fmt.Sprintf("%d applied, %d pending", len(applied), pending)
+	if len(late) > 0 {
+		summary += fmt.Sprintf(" (%d out of order)", len(late))
+	}
+	if len(applied) == 0 {
+		return summary + ", no version applied yet"
+	}
+	return summary + ", current version " + applied[len(applied)-1].Version
+}
+
+// outOfOrder returns the versions of pending migrations that sort before
+// the newest applied migration, usually merged from another branch after
+// later migrations were applied
+func outOfOrder(files []MigrationFile, applied []storage.Migration) map[string]bool {
+	var newest string
+	for _, m := range applied {
+		if newest == "" || versionLess(newest, m.Version) {
+			newest = m.Version
+		}
+	}
+
+	appliedSet := make(map[string]bool)
+	for _, m := range applied {
+		appliedSet[m.Version] = true
+	}
+
+	result := make(map[string]bool)
+	for _, file := range files {
+		if !appliedSet[file.Version] && newest != "" && versionLess(file.Version, newest) {
+			result[file.Version] = true
+		}
+	}
+	return result
+}
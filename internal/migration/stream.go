@@ -0,0 +1,110 @@
+package migration
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// parseMigrationStream scans the migration file named name in fsys line by
+// line, returning its DOWN section and the directives it contains without
+// retaining the UP section
+func parseMigrationStream(fsys fs.FS, name string, markers sectionMarkers) (downSQL string, directives map[string]bool, err error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var inDown bool
+	var downLines []string
+	directives = make(map[string]bool)
+
+	for {
+		line, err := r.ReadString('\n')
+		if line != "" {
+			trimmed := strings.TrimSpace(line)
+			switch {
+			case isMarker(trimmed, markers.up):
+				inDown = false
+			case isMarker(trimmed, markers.down):
+				inDown = true
+			case inDown:
+				downLines = append(downLines, strings.TrimRight(line, "\r\n"))
+			}
+
+			for _, name := range []string{"migrate:manual", "migrate:independent", "migrate:irreversible"} {
+				if isDirective(trimmed, name) {
+					directives[name] = true
+				}
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read file: %w", err)
+		}
+	}
+
+	return strings.TrimSpace(strings.Join(downLines, "\n")), directives, nil
+}
+
+// upSectionReader reads only the UP section of a migration file
+type upSectionReader struct {
+	f       fs.File
+	r       *bufio.Reader
+	markers sectionMarkers
+	inUp    bool
+	done    bool
+	buf     []byte
+}
+
+// openUpSection opens the migration file named name in fsys for streaming
+// its UP section
+func openUpSection(fsys fs.FS, name string, markers sectionMarkers) (io.ReadCloser, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &upSectionReader{f: f, r: bufio.NewReader(f), markers: markers}, nil
+}
+
+// Read implements io.Reader
+func (u *upSectionReader) Read(p []byte) (int, error) {
+	for len(u.buf) == 0 {
+		if u.done {
+			return 0, io.EOF
+		}
+
+		line, err := u.r.ReadString('\n')
+		switch {
+		case isMarker(line, u.markers.up):
+			u.inUp = true
+		case isMarker(line, u.markers.down):
+			u.done = u.inUp
+			u.inUp = false
+		case u.inUp:
+			u.buf = []byte(line)
+		}
+
+		if err == io.EOF {
+			u.done = true
+		} else if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, u.buf)
+	u.buf = u.buf[n:]
+	return n, nil
+}
+
+// Close closes the underlying file
+func (u *upSectionReader) Close() error {
+	return u.f.Close()
+}
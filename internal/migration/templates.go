@@ -0,0 +1,112 @@
+package migration
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// builtinTemplate generates the UP and DOWN SQL of a routine migration from
+// the argument given after its name, as in "create-table:users"
+type builtinTemplate struct {
+	// usage shows the argument the template expects
+	usage  string
+	render func(arg string) (upSQL, downSQL string, err error)
+}
+
+// builtinTemplates are the templates create --template accepts by name
+// instead of a template file
+var builtinTemplates = map[string]builtinTemplate{
+	"create-table": {
+		usage: "create-table:<table>",
+		render: func(arg string) (string, string, error) {
+			table, err := templateIdentifier(arg)
+			if err != nil {
+				return "", "", err
+			}
+			up := fmt.Sprintf(`CREATE TABLE %s (
+    id INTEGER PRIMARY KEY,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);`, table)
+			return up, fmt.Sprintf("DROP TABLE %s;", table), nil
+		},
+	},
+	"add-column": {
+		usage: "add-column:<table>.<column>",
+		render: func(arg string) (string, string, error) {
+			table, column, err := templateColumn(arg)
+			if err != nil {
+				return "", "", err
+			}
+			return fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s TEXT;", table, column),
+				fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", table, column), nil
+		},
+	},
+	"create-index": {
+		usage: "create-index:<table>.<column>[,<column>...]",
+		render: func(arg string) (string, string, error) {
+			table, columns, ok := strings.Cut(arg, ".")
+			if !ok {
+				return "", "", fmt.Errorf("expected <table>.<column>, got %q", arg)
+			}
+			if _, err := templateIdentifier(table); err != nil {
+				return "", "", err
+			}
+			names := strings.Split(columns, ",")
+			for _, name := range names {
+				if _, err := templateIdentifier(name); err != nil {
+					return "", "", err
+				}
+			}
+			index := fmt.Sprintf("idx_%s_%s", table, strings.Join(names, "_"))
+			return fmt.Sprintf("CREATE INDEX %s ON %s (%s);", index, table, strings.Join(names, ", ")),
+				fmt.Sprintf("DROP INDEX %s;", index), nil
+		},
+	},
+}
+
+// templateIdentifierPattern matches the table and column names accepted by
+// built-in templates
+var templateIdentifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// templateIdentifier returns name if it is a valid identifier
+func templateIdentifier(name string) (string, error) {
+	if !templateIdentifierPattern.MatchString(name) {
+		return "", fmt.Errorf("invalid identifier %q: use letters, digits and underscores", name)
+	}
+	return name, nil
+}
+
+// templateColumn splits and checks a "<table>.<column>" argument
+func templateColumn(arg string) (table, column string, err error) {
+	table, column, ok := strings.Cut(arg, ".")
+	if !ok {
+		return "", "", fmt.Errorf("expected <table>.<column>, got %q", arg)
+	}
+	if _, err := templateIdentifier(table); err != nil {
+		return "", "", err
+	}
+	if _, err := templateIdentifier(column); err != nil {
+		return "", "", err
+	}
+	return table, column, nil
+}
+
+// renderBuiltin renders spec if it names a built-in template, reporting
+// false if it doesn't, in which case it is the path of a template file
+func renderBuiltin(spec string) (upSQL, downSQL string, ok bool, err error) {
+	name, arg, found := strings.Cut(spec, ":")
+	tmpl, known := builtinTemplates[name]
+	if !found || !known {
+		return "", "", false, nil
+	}
+	if arg == "" {
+		return "", "", true, fmt.Errorf("template %s needs an argument: %s", name, tmpl.usage)
+	}
+	upSQL, downSQL, err = tmpl.render(arg)
+	if err != nil {
+		return "", "", true, fmt.Errorf("template %s: %w", tmpl.usage, err)
+	}
+	return upSQL, downSQL, true, nil
+}
@@ -0,0 +1,60 @@
+package migration
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rubenmeza/turso-migrate/internal/storage"
+)
+
+// ForceUnlockOptions controls how ForceUnlock confirms removing the lock
+type ForceUnlockOptions struct {
+	// Yes removes the lock without asking
+	Yes bool
+	// Input is where the confirmation is read from
+	Input io.Reader
+	// Phrase, if set, must be typed to confirm instead of "y"
+	Phrase string
+}
+
+// ForceUnlock removes the migration lock whoever holds it, after printing
+// the holder and asking for confirmation. It is meant for locks left by a
+// crashed process; removing the lock of a run still in progress lets
+// another run apply the same migrations concurrently.
+func (e *Engine) ForceUnlock(opts ForceUnlockOptions) error {
+	if err := e.checkWritable("remove the migration lock"); err != nil {
+		return err
+	}
+
+	owner, lockedAt, err := e.storage.LockHolder()
+	if errors.Is(err, storage.ErrNotLocked) {
+		fmt.Fprintln(e.out, "Migrations are not locked")
+		return nil
+	}
+	if err != nil {
+		return newError(CodeStorageFailed, "", err, "failed to read the migration lock")
+	}
+
+	fmt.Fprintf(e.out, "Lock held by %s since %s (%s ago)\n",
+		owner, lockedAt.Local().Format("2006-01-02 15:04:05"), time.Since(lockedAt).Round(time.Second))
+
+	if !opts.Yes {
+		prompt := e.paint(colorRed, "WARNING:") + " if that run is still in progress, another run may apply the same migrations concurrently. Remove the lock?"
+		ok, err := Confirm(bufio.NewReader(opts.Input), e.prompt, prompt, opts.Phrase)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("force-unlock cancelled; the lock was kept")
+		}
+	}
+
+	if err := e.storage.ForceReleaseLock(); err != nil {
+		return newError(CodeStorageFailed, "", err, "failed to remove the migration lock")
+	}
+	fmt.Fprintln(e.out, "Lock removed")
+	return nil
+}
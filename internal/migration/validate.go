@@ -0,0 +1,168 @@
+package migration
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ValidateOptions controls which checks Validate performs
+type ValidateOptions struct {
+	// ParseOnly only checks that files have valid names and can be parsed
+	ParseOnly bool
+	// OnlyNew only checks files not yet committed to git
+	OnlyNew bool
+	// FailFast stops at the first problem instead of reporting all of them
+	FailFast bool
+}
+
+// Validate checks migration files without touching the database,
+// reporting every problem found unless FailFast is set
+func (e *Engine) Validate(opts ValidateOptions) error {
+	paths, err := e.migrationPaths()
+	if err != nil {
+		return fmt.Errorf("failed to list migration files: %w", err)
+	}
+
+	// With OnlyNew every file is still read, so that a new file reusing the
+	// version of a committed one is caught, but only problems involving new
+	// files are reported
+	selected := func(string) bool { return true }
+	if opts.OnlyNew {
+		if uncommitted, ok := e.uncommitted(); ok {
+			selected = func(name string) bool { return uncommitted[e.relPath(name)] }
+		} else {
+			fmt.Fprintln(e.out, "Not in a git repository, validating all files")
+		}
+	}
+
+	var issues, checked int
+	// versions maps each version to the name of the first file using it
+	versions := make(map[string]string)
+
+	report := func(path, format string, args ...any) {
+		fmt.Fprintf(e.out, "%s %s: %s\n", e.paint(colorRed, "✗"), path, fmt.Sprintf(format, args...))
+		issues++
+	}
+
+	for _, name := range paths {
+		if opts.FailFast && issues > 0 {
+			break
+		}
+		check := selected(name)
+		if check {
+			checked++
+		}
+
+		path := e.displayPath(name)
+		file, err := e.parseMigrationFile(name)
+		if err != nil {
+			if check {
+				report(path, "%v", err)
+			}
+			continue
+		}
+
+		if opts.ParseOnly {
+			continue
+		}
+
+		other, exists := versions[file.Version]
+		switch {
+		case !exists:
+			versions[file.Version] = name
+		case check:
+			report(path, "duplicate version %s (also used by %s)", file.Version, e.displayPath(other))
+		case selected(other):
+			report(e.displayPath(other), "duplicate version %s (also used by %s)", file.Version, path)
+		}
+
+		if !check {
+			continue
+		}
+
+		if file.UpSQL == "" && !e.streaming {
+			report(path, "empty UP section")
+		}
+
+		if file.markers != nil {
+			problem, err := e.checkMarkers(name, *file.markers)
+			if err != nil {
+				report(path, "%v", err)
+			} else if problem != "" {
+				report(path, "%s", problem)
+			}
+		}
+	}
+
+	if !opts.ParseOnly && !(opts.FailFast && issues > 0) {
+		orphans, err := e.orphanDownFiles()
+		if err != nil {
+			return fmt.Errorf("failed to list migration files: %w", err)
+		}
+		for _, name := range orphans {
+			if selected(name) {
+				report(e.displayPath(name), "DOWN file without a matching %s file", upSuffix)
+			}
+		}
+	}
+
+	widths := make(map[int]bool)
+	for version := range versions {
+		widths[len(version)] = true
+	}
+	if len(widths) > 1 {
+		fmt.Fprintln(e.out, "Warning: migration versions mix different widths")
+	}
+
+	fmt.Fprintf(e.out, "Checked %d file(s), found %d issue(s)\n", checked, issues)
+
+	if issues > 0 {
+		return newError(CodeInvalidMigration, "", nil, "%d migration issue(s) found", issues)
+	}
+	return nil
+}
+
+// checkMarkers checks that the file named name has exactly one UP marker
+// and at most one DOWN marker after it, returning a description of the
+// problem found, if any
+func (e *Engine) checkMarkers(name string, markers sectionMarkers) (string, error) {
+	f, err := e.fsys.Open(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	defer f.Close()
+
+	var ups, downs int
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadString('\n')
+		line = strings.TrimSpace(line)
+		switch {
+		case isMarker(line, markers.up):
+			if downs > 0 {
+				return fmt.Sprintf("UP marker %q after the DOWN marker", markers.up), nil
+			}
+			ups++
+		case isMarker(line, markers.down):
+			downs++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read file: %w", err)
+		}
+	}
+
+	switch {
+	case ups == 0:
+		return fmt.Sprintf("DOWN marker %q without an UP marker %q", markers.down, markers.up), nil
+	case ups > 1:
+		return fmt.Sprintf("%d UP markers %q, expected one", ups, markers.up), nil
+	case downs > 1:
+		return fmt.Sprintf("%d DOWN markers %q, expected at most one", downs, markers.down), nil
+	}
+	return "", nil
+}
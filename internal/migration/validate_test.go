@@ -0,0 +1,90 @@
+package migration
+
+import (
+	"io"
+	"os/exec"
+	"testing"
+)
+
+// gitInit makes dir a git repository and commits everything in it
+func gitInit(t *testing.T, dir string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init"},
+	} {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+}
+
+func TestValidateOnlyNewDuplicateVersion(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"001_create_users.sql": "-- ==== UP ====\nCREATE TABLE users (id INTEGER);\n",
+		"002_add_posts.sql":    "-- ==== UP ====\nCREATE TABLE posts (id INTEGER);\n",
+	})
+	gitInit(t, dir)
+
+	e := NewEngine(nil, dir)
+	e.SetOutput(io.Discard)
+	if err := e.Validate(ValidateOptions{OnlyNew: true}); err != nil {
+		t.Fatalf("Validate with no new files: %v", err)
+	}
+
+	writeFiles(t, dir, map[string]string{
+		"002_add_comments.sql": "-- ==== UP ====\nCREATE TABLE comments (id INTEGER);\n",
+	})
+	if err := e.Validate(ValidateOptions{OnlyNew: true}); err == nil {
+		t.Error("Validate accepted a new file reusing a committed version")
+	}
+}
+
+func TestMixedMarkerStyles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"001_native.sql":      "-- ==== UP ====\nCREATE TABLE a (id INTEGER);\n-- ==== DOWN ====\nDROP TABLE a;\n",
+		"002_sql_migrate.sql": "-- +migrate Up\nCREATE TABLE b (id INTEGER);\n-- +migrate Down\nDROP TABLE b;\n",
+		"003_goose.sql":       "-- +goose Up\nCREATE TABLE c (id INTEGER);\n-- +goose Down\nDROP TABLE c;\n",
+		"004_quoted.sql":      "-- ==== UP ====\nINSERT INTO notes VALUES ('-- ==== DOWN ====');\n-- ==== DOWN ====\nDELETE FROM notes;\n",
+		"005_no_markers.sql":  "CREATE TABLE e (id INTEGER);\n",
+	})
+	want := []struct{ up, down string }{
+		{"CREATE TABLE a (id INTEGER);", "DROP TABLE a;"},
+		{"CREATE TABLE b (id INTEGER);", "DROP TABLE b;"},
+		{"CREATE TABLE c (id INTEGER);", "DROP TABLE c;"},
+		{"INSERT INTO notes VALUES ('-- ==== DOWN ====');", "DELETE FROM notes;"},
+		{"CREATE TABLE e (id INTEGER);", ""},
+	}
+
+	e := NewEngine(nil, dir)
+	e.SetOutput(io.Discard)
+	if err := e.Validate(ValidateOptions{}); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+
+	for _, streaming := range []bool{false, true} {
+		e.SetStreaming(streaming)
+		files, err := e.loadMigrationFiles()
+		if err != nil {
+			t.Fatalf("loadMigrationFiles: %v", err)
+		}
+		if len(files) != len(want) {
+			t.Fatalf("loaded %d files, want %d", len(files), len(want))
+		}
+		for i, file := range files {
+			if !streaming && file.UpSQL != want[i].up {
+				t.Errorf("%s: UP = %q, want %q", file.Path, file.UpSQL, want[i].up)
+			}
+			if file.DownSQL != want[i].down {
+				t.Errorf("%s (streaming %v): DOWN = %q, want %q", file.Path, streaming, file.DownSQL, want[i].down)
+			}
+		}
+	}
+}
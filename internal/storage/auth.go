@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var (
+	// ErrAuth is returned when the database rejects the auth token
+	ErrAuth = errors.New("auth token invalid or expired")
+	// ErrUnreachable is returned when the database cannot be reached
+	ErrUnreachable = errors.New("could not reach database")
+)
+
+// authStatusPattern matches authentication failures reported by the libSQL client
+var authStatusPattern = regexp.MustCompile(`error code 40[13]\b`)
+
+// CheckAuth runs a cheap authenticated query, returning an error wrapping
+// ErrAuth if the token is rejected or ErrUnreachable on network failures
+func (s *TursoStorage) CheckAuth() error {
+	var one int
+	return classifyError(s.db.QueryRow(`SELECT 1`).Scan(&one))
+}
+
+// Ping runs a trivial query to check that the database can be reached,
+// since opening it doesn't connect. Transient failures are retried like
+// ExecuteSQL. The error names the host but never the auth token.
+func (s *TursoStorage) Ping() error {
+	return s.PingContext(context.Background())
+}
+
+// PingContext is Ping with a context
+func (s *TursoStorage) PingContext(ctx context.Context) error {
+	err := s.withRetry(ctx, func() error {
+		var one int
+		return s.db.QueryRowContext(ctx, `SELECT 1`).Scan(&one)
+	})
+	if err != nil {
+		host, _ := s.host()
+		return fmt.Errorf("could not connect to Turso database at %s: %w", host, classifyError(err))
+	}
+	return nil
+}
+
+// classifyError wraps err with ErrAuth or ErrUnreachable when it is an
+// authentication or connectivity failure
+func classifyError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case authStatusPattern.MatchString(err.Error()):
+		return fmt.Errorf("%w: %v", ErrAuth, err)
+	case isTransient(err):
+		return fmt.Errorf("%w: %v", ErrUnreachable, err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,329 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect generates the SQL statements TursoStorage uses to manage the
+// tracking table and inspect the database. Table names passed to a Dialect
+// are already qualified by schema and quoted.
+type Dialect interface {
+	// Name identifies the dialect, e.g. for the --dialect flag
+	Name() string
+	// QuoteIdentifier quotes a table, column or schema name
+	QuoteIdentifier(name string) string
+	// AttachDatabase attaches the database given as argument under alias
+	AttachDatabase(alias string) string
+	// CreateTrackingTable creates the tracking table if it doesn't exist
+	CreateTrackingTable(table string) string
+	// AddedColumns lists the tracking table columns added since it was
+	// first created, which InitSchema adds to tables created earlier
+	AddedColumns() []Column
+	// ColumnExists counts columns of a table; its arguments are the
+	// unquoted table name, schema name and column name
+	ColumnExists() string
+	// AddColumn adds a column to the tracking table
+	AddColumn(table, column, definition string) string
+	// PadColumns wraps table in a subquery that reads NULL in place of the
+	// missing columns, so the Select statements can read a tracking table
+	// created before those columns were added
+	PadColumns(table string, missing []string) string
+	// RecordMigration upserts a record; its arguments are the version, name,
+	// applied time, run ID, checksum, note and duration in milliseconds
+	RecordMigration(table string) string
+	// RemoveMigration deletes the record of the version given as argument
+	RemoveMigration(table string) string
+	// MarkRolledBack sets the rollback time, given as the first argument,
+	// of the version given as the second
+	MarkRolledBack(table string) string
+	// SelectApplied lists the version, name, applied time, run ID, checksum,
+	// note and duration in milliseconds of applied migrations ordered by
+	// version
+	SelectApplied(table string) string
+	// SelectAppliedRange is SelectApplied restricted to versions between
+	// the first and second arguments inclusive, each given twice; an empty
+	// bound is open
+	SelectAppliedRange(table string) string
+	// SelectLastApplied is SelectApplied for the number of newest applied
+	// migrations given as argument, newest first
+	SelectLastApplied(table string) string
+	// SelectAppliedVersions lists only the versions of applied migrations,
+	// newest first if descending
+	SelectAppliedVersions(table string, descending bool) string
+	// CountApplied counts applied records of the version given as argument
+	CountApplied(table string) string
+	// SelectCurrentVersion returns the highest applied version
+	SelectCurrentVersion(table string) string
+	// DropTable drops a table if it exists
+	DropTable(table string) string
+	// CreateLockTable creates the single-row lock table if it doesn't exist
+	CreateLockTable(table string) string
+	// InsertLock inserts the lock row unless it already exists, affecting no
+	// rows in that case; its arguments are the owner and lock time
+	InsertLock(table string) string
+	// DeleteLock deletes the lock row, whoever holds it
+	DeleteLock(table string) string
+	// DeleteOwnLock deletes the lock row if it is held by the owner given
+	// as argument
+	DeleteOwnLock(table string) string
+	// RefreshLock sets the lock time, given as the first argument, of the
+	// lock row if it is held by the owner given as the second
+	RefreshLock(table string) string
+	// DeleteStaleLock deletes the lock row if it was taken before the time
+	// given as argument
+	DeleteStaleLock(table string) string
+	// SelectLock returns the owner and lock time of the lock row
+	SelectLock(table string) string
+	// TableExists counts tables in schema named by the argument
+	TableExists(schema string) string
+	// SelectSchema lists the CREATE statements of user-defined objects,
+	// excluding the tracking and lock tables named by the arguments
+	SelectSchema() string
+	// ServerVersion returns the database engine's version
+	ServerVersion() string
+}
+
+// Column is a column of the tracking table with its SQL definition
+type Column struct {
+	Name       string
+	Definition string
+}
+
+// SQLiteDialect is the Dialect for SQLite and libSQL databases
+type SQLiteDialect struct{}
+
+// dialects are the dialects selectable by name
+var dialects = map[string]Dialect{
+	"sqlite": SQLiteDialect{},
+}
+
+// DialectByName returns the dialect registered under name
+func DialectByName(name string) (Dialect, error) {
+	dialect, ok := dialects[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown dialect %q", name)
+	}
+	return dialect, nil
+}
+
+// Name implements Dialect
+func (SQLiteDialect) Name() string {
+	return "sqlite"
+}
+
+// QuoteIdentifier implements Dialect
+func (SQLiteDialect) QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// AttachDatabase implements Dialect
+func (d SQLiteDialect) AttachDatabase(alias string) string {
+	return fmt.Sprintf("ATTACH DATABASE ? AS %s", d.QuoteIdentifier(alias))
+}
+
+// CreateTrackingTable implements Dialect
+func (SQLiteDialect) CreateTrackingTable(table string) string {
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			version TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			rolled_back_at DATETIME,
+			run_id TEXT,
+			checksum TEXT,
+			duration_ms INTEGER,
+			note TEXT
+		)
+	`, table)
+}
+
+// AddedColumns implements Dialect
+func (SQLiteDialect) AddedColumns() []Column {
+	return []Column{
+		{"rolled_back_at", "DATETIME"},
+		{"run_id", "TEXT"},
+		{"checksum", "TEXT"},
+		{"duration_ms", "INTEGER"},
+		{"note", "TEXT"},
+	}
+}
+
+// ColumnExists implements Dialect
+func (SQLiteDialect) ColumnExists() string {
+	return `SELECT COUNT(*) FROM pragma_table_info(?, ?) WHERE name = ?`
+}
+
+// AddColumn implements Dialect
+func (SQLiteDialect) AddColumn(table, column, definition string) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)
+}
+
+// PadColumns implements Dialect
+func (d SQLiteDialect) PadColumns(table string, missing []string) string {
+	var b strings.Builder
+	b.WriteString("(SELECT *")
+	for _, column := range missing {
+		fmt.Fprintf(&b, ", NULL AS %s", d.QuoteIdentifier(column))
+	}
+	fmt.Fprintf(&b, " FROM %s)", table)
+	return b.String()
+}
+
+// RecordMigration implements Dialect
+func (SQLiteDialect) RecordMigration(table string) string {
+	return fmt.Sprintf(`
+		INSERT INTO %s (version, name, applied_at, run_id, checksum, note, duration_ms)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (version) DO UPDATE SET
+			name = excluded.name,
+			applied_at = excluded.applied_at,
+			run_id = excluded.run_id,
+			checksum = excluded.checksum,
+			note = excluded.note,
+			duration_ms = excluded.duration_ms,
+			rolled_back_at = NULL
+	`, table)
+}
+
+// RemoveMigration implements Dialect
+func (SQLiteDialect) RemoveMigration(table string) string {
+	return fmt.Sprintf(`DELETE FROM %s WHERE version = ?`, table)
+}
+
+// MarkRolledBack implements Dialect
+func (SQLiteDialect) MarkRolledBack(table string) string {
+	return fmt.Sprintf(`UPDATE %s SET rolled_back_at = ? WHERE version = ?`, table)
+}
+
+// SelectApplied implements Dialect
+func (SQLiteDialect) SelectApplied(table string) string {
+	return fmt.Sprintf(`
+		SELECT version, name, applied_at, COALESCE(run_id, ''), COALESCE(checksum, ''), COALESCE(note, ''), COALESCE(duration_ms, 0)
+		FROM %s 
+		WHERE rolled_back_at IS NULL
+		ORDER BY CAST(version AS INTEGER) ASC, version ASC
+	`, table)
+}
+
+// SelectAppliedRange implements Dialect
+func (SQLiteDialect) SelectAppliedRange(table string) string {
+	return fmt.Sprintf(`
+		SELECT version, name, applied_at, COALESCE(run_id, ''), COALESCE(checksum, ''), COALESCE(note, ''), COALESCE(duration_ms, 0)
+		FROM %s
+		WHERE rolled_back_at IS NULL
+			AND (? = '' OR CAST(version AS INTEGER) >= CAST(? AS INTEGER))
+			AND (? = '' OR CAST(version AS INTEGER) <= CAST(? AS INTEGER))
+		ORDER BY CAST(version AS INTEGER) ASC, version ASC
+	`, table)
+}
+
+// SelectLastApplied implements Dialect
+func (SQLiteDialect) SelectLastApplied(table string) string {
+	return fmt.Sprintf(`
+		SELECT version, name, applied_at, COALESCE(run_id, ''), COALESCE(checksum, ''), COALESCE(note, ''), COALESCE(duration_ms, 0)
+		FROM %s
+		WHERE rolled_back_at IS NULL
+		ORDER BY CAST(version AS INTEGER) DESC, version DESC
+		LIMIT ?
+	`, table)
+}
+
+// SelectAppliedVersions implements Dialect
+func (SQLiteDialect) SelectAppliedVersions(table string, descending bool) string {
+	order := "ASC"
+	if descending {
+		order = "DESC"
+	}
+	return fmt.Sprintf(`
+		SELECT version
+		FROM %s
+		WHERE rolled_back_at IS NULL
+		ORDER BY CAST(version AS INTEGER) %s, version %s
+	`, table, order, order)
+}
+
+// CountApplied implements Dialect
+func (SQLiteDialect) CountApplied(table string) string {
+	return fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE version = ? AND rolled_back_at IS NULL`, table)
+}
+
+// SelectCurrentVersion implements Dialect
+func (SQLiteDialect) SelectCurrentVersion(table string) string {
+	return fmt.Sprintf(`
+		SELECT version 
+		FROM %s 
+		WHERE rolled_back_at IS NULL
+		ORDER BY CAST(version AS INTEGER) DESC, version DESC 
+		LIMIT 1
+	`, table)
+}
+
+// DropTable implements Dialect
+func (SQLiteDialect) DropTable(table string) string {
+	return fmt.Sprintf(`DROP TABLE IF EXISTS %s`, table)
+}
+
+// CreateLockTable implements Dialect
+func (SQLiteDialect) CreateLockTable(table string) string {
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			owner TEXT NOT NULL,
+			locked_at DATETIME NOT NULL
+		)
+	`, table)
+}
+
+// InsertLock implements Dialect
+func (SQLiteDialect) InsertLock(table string) string {
+	return fmt.Sprintf(`INSERT INTO %s (id, owner, locked_at) VALUES (1, ?, ?) ON CONFLICT (id) DO NOTHING`, table)
+}
+
+// DeleteLock implements Dialect
+func (SQLiteDialect) DeleteLock(table string) string {
+	return fmt.Sprintf(`DELETE FROM %s WHERE id = 1`, table)
+}
+
+// DeleteOwnLock implements Dialect
+func (SQLiteDialect) DeleteOwnLock(table string) string {
+	return fmt.Sprintf(`DELETE FROM %s WHERE id = 1 AND owner = ?`, table)
+}
+
+// RefreshLock implements Dialect
+func (SQLiteDialect) RefreshLock(table string) string {
+	return fmt.Sprintf(`UPDATE %s SET locked_at = ? WHERE id = 1 AND owner = ?`, table)
+}
+
+// DeleteStaleLock implements Dialect
+func (SQLiteDialect) DeleteStaleLock(table string) string {
+	return fmt.Sprintf(`DELETE FROM %s WHERE id = 1 AND locked_at < ?`, table)
+}
+
+// SelectLock implements Dialect
+func (SQLiteDialect) SelectLock(table string) string {
+	return fmt.Sprintf(`SELECT owner, locked_at FROM %s WHERE id = 1`, table)
+}
+
+// TableExists implements Dialect
+func (d SQLiteDialect) TableExists(schema string) string {
+	return fmt.Sprintf(`SELECT COUNT(*) FROM %s.sqlite_master WHERE type = 'table' AND name = ?`, d.QuoteIdentifier(schema))
+}
+
+// SelectSchema implements Dialect
+func (SQLiteDialect) SelectSchema() string {
+	return `
+		SELECT sql
+		FROM sqlite_master
+		WHERE sql IS NOT NULL
+			AND name NOT LIKE 'sqlite_%'
+			AND name NOT LIKE 'libsql_%'
+			AND name NOT IN (?, ?)
+		ORDER BY type, name
+	`
+}
+
+// ServerVersion implements Dialect
+func (SQLiteDialect) ServerVersion() string {
+	return `SELECT sqlite_version()`
+}
@@ -0,0 +1,143 @@
+package storage
+
+import "fmt"
+
+// FlywayDialect keeps the migration history in Flyway's own
+// flyway_schema_history format, so a database managed by Flyway can be
+// migrated further by turso-migrate and the other way around. It reads
+// and writes Flyway's columns, maps a migration's name to Flyway's
+// description by replacing underscores with spaces, and uses SQLite for
+// everything else, including the lock table.
+//
+// Flyway checksums are CRC32 values Flyway computes itself, so records
+// written here have none; run "flyway repair" to fill them in before
+// "flyway validate". turso-migrate's run IDs, checksums, notes and soft
+// rollbacks have no Flyway column and are not kept.
+type FlywayDialect struct {
+	SQLiteDialect
+}
+
+// flywayApplied restricts Flyway's history to successful versioned
+// migrations and baselines, leaving out repeatable migrations, which have
+// no version, and undo and deleted entries
+const flywayApplied = `version IS NOT NULL AND success = 1 AND type NOT IN ('UNDO_SQL', 'UNDO_JDBC', 'DELETE', 'SCHEMA')`
+
+// flywayColumns selects Flyway's columns in the order of SelectApplied,
+// turning the description back into the name used in filenames
+const flywayColumns = `version, REPLACE(description, ' ', '_'), installed_on, '', '', '', COALESCE(execution_time, 0)`
+
+// Name implements Dialect
+func (FlywayDialect) Name() string {
+	return "flyway"
+}
+
+// CreateTrackingTable implements Dialect
+func (FlywayDialect) CreateTrackingTable(table string) string {
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			installed_rank INT NOT NULL PRIMARY KEY,
+			version VARCHAR(50),
+			description VARCHAR(200) NOT NULL,
+			type VARCHAR(20) NOT NULL,
+			script VARCHAR(1000) NOT NULL,
+			checksum INT,
+			installed_by VARCHAR(100) NOT NULL,
+			installed_on TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			execution_time INT NOT NULL,
+			success BOOLEAN NOT NULL
+		)
+	`, table)
+}
+
+// AddedColumns implements Dialect; Flyway's table is never altered
+func (FlywayDialect) AddedColumns() []Column {
+	return nil
+}
+
+// RecordMigration implements Dialect. Parameters are numbered since the
+// run ID, checksum and note given as the fourth to sixth have no column,
+// and the applied time given as the third is replaced by the database's
+// CURRENT_TIMESTAMP, the format Flyway itself writes.
+func (FlywayDialect) RecordMigration(table string) string {
+	return fmt.Sprintf(`
+		INSERT INTO %[1]s (installed_rank, version, description, type, script, checksum, installed_by, installed_on, execution_time, success)
+		SELECT COALESCE(MAX(installed_rank), 0) + 1, ?1, REPLACE(?2, '_', ' '), 'SQL', 'V' || ?1 || '__' || ?2 || '.sql',
+			NULL, 'turso-migrate', CURRENT_TIMESTAMP, ?7, 1
+		FROM %[1]s
+	`, table)
+}
+
+// RemoveMigration implements Dialect
+func (FlywayDialect) RemoveMigration(table string) string {
+	return fmt.Sprintf(`DELETE FROM %s WHERE version = ?`, table)
+}
+
+// MarkRolledBack implements Dialect. Flyway has no record of reverted
+// migrations, so the record of the version given as the second argument
+// is removed.
+func (FlywayDialect) MarkRolledBack(table string) string {
+	return fmt.Sprintf(`DELETE FROM %s WHERE version = ?2`, table)
+}
+
+// SelectApplied implements Dialect
+func (FlywayDialect) SelectApplied(table string) string {
+	return fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE %s
+		ORDER BY CAST(version AS INTEGER) ASC, version ASC
+	`, flywayColumns, table, flywayApplied)
+}
+
+// SelectAppliedRange implements Dialect
+func (FlywayDialect) SelectAppliedRange(table string) string {
+	return fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE %s
+			AND (? = '' OR CAST(version AS INTEGER) >= CAST(? AS INTEGER))
+			AND (? = '' OR CAST(version AS INTEGER) <= CAST(? AS INTEGER))
+		ORDER BY CAST(version AS INTEGER) ASC, version ASC
+	`, flywayColumns, table, flywayApplied)
+}
+
+// SelectLastApplied implements Dialect
+func (FlywayDialect) SelectLastApplied(table string) string {
+	return fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE %s
+		ORDER BY CAST(version AS INTEGER) DESC, version DESC
+		LIMIT ?
+	`, flywayColumns, table, flywayApplied)
+}
+
+// SelectAppliedVersions implements Dialect
+func (FlywayDialect) SelectAppliedVersions(table string, descending bool) string {
+	order := "ASC"
+	if descending {
+		order = "DESC"
+	}
+	return fmt.Sprintf(`
+		SELECT version
+		FROM %s
+		WHERE %s
+		ORDER BY CAST(version AS INTEGER) %s, version %s
+	`, table, flywayApplied, order, order)
+}
+
+// CountApplied implements Dialect
+func (FlywayDialect) CountApplied(table string) string {
+	return fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE version = ? AND %s`, table, flywayApplied)
+}
+
+// SelectCurrentVersion implements Dialect
+func (FlywayDialect) SelectCurrentVersion(table string) string {
+	return fmt.Sprintf(`
+		SELECT version
+		FROM %s
+		WHERE %s
+		ORDER BY CAST(version AS INTEGER) DESC, version DESC
+		LIMIT 1
+	`, table, flywayApplied)
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"testing"
+)
+
+func TestFlywayDialectReadsFlywayHistory(t *testing.T) {
+	s, _ := openTemp(t, Options{Table: "flyway_schema_history", Dialect: FlywayDialect{}})
+
+	// Rows as Flyway writes them: a baseline, a versioned migration, a
+	// failed one and a repeatable one
+	_, err := s.db.Exec(`
+		INSERT INTO flyway_schema_history
+			(installed_rank, version, description, type, script, checksum, installed_by, installed_on, execution_time, success)
+		VALUES
+			(1, '1', '<< Flyway Baseline >>', 'BASELINE', '<< Flyway Baseline >>', NULL, 'flyway', '2024-01-05 10:00:00', 0, 1),
+			(2, '2', 'Create users', 'SQL', 'V2__Create_users.sql', 123456, 'flyway', '2024-01-05 10:00:01', 12, 1),
+			(3, '3', 'Broken', 'SQL', 'V3__Broken.sql', 654321, 'flyway', '2024-01-05 10:00:02', 3, 0),
+			(4, NULL, 'Views', 'SQL', 'R__Views.sql', 111, 'flyway', '2024-01-05 10:00:03', 1, 1)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := s.GetAppliedMigrations()
+	if err != nil {
+		t.Fatalf("GetAppliedMigrations: %v", err)
+	}
+	if len(applied) != 2 || applied[0].Version != "1" || applied[1].Version != "2" {
+		t.Fatalf("GetAppliedMigrations() = %+v, want the baseline and version 2", applied)
+	}
+	if applied[1].Name != "Create_users" || applied[1].Duration.Milliseconds() != 12 {
+		t.Errorf("got %+v, want name Create_users taking 12ms", applied[1])
+	}
+	if version, err := s.GetCurrentVersion(); err != nil || version != "2" {
+		t.Errorf("GetCurrentVersion() = %q, %v; want 2", version, err)
+	}
+	if ok, err := s.IsMigrationApplied("3"); err != nil || ok {
+		t.Errorf("IsMigrationApplied(3) = %v, %v; want the failed migration pending", ok, err)
+	}
+
+	if err := s.RecordMigration("3", "Broken", "run1", "sha", "note", 0); err != nil {
+		t.Fatalf("RecordMigration: %v", err)
+	}
+	var rank int
+	var description, typ, script, installedBy string
+	var success bool
+	err = s.db.QueryRow(`
+		SELECT installed_rank, description, type, script, installed_by, success
+		FROM flyway_schema_history
+		ORDER BY installed_rank DESC LIMIT 1
+	`).Scan(&rank, &description, &typ, &script, &installedBy, &success)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rank != 5 || description != "Broken" || typ != "SQL" || script != "V3__Broken.sql" || installedBy != "turso-migrate" || !success {
+		t.Errorf("recorded rank %d, description %q, type %q, script %q, installed by %q, success %v",
+			rank, description, typ, script, installedBy, success)
+	}
+	if version, err := s.GetCurrentVersion(); err != nil || version != "3" {
+		t.Errorf("GetCurrentVersion() after recording = %q, %v; want 3", version, err)
+	}
+
+	// The table must keep Flyway's columns only
+	if exists, err := s.hasColumn(s.table, "run_id"); err != nil || exists {
+		t.Errorf("run_id column exists = %v, %v; want Flyway's table left unaltered", exists, err)
+	}
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DatabaseInfo describes the database a TursoStorage is connected to
+type DatabaseInfo struct {
+	// URL is the database URL with any auth token redacted
+	URL string
+	// Host is the database host, or the file path for local databases
+	Host string
+	// Remote is true for libsql://, http(s):// and ws(s):// URLs
+	Remote bool
+	// SQLiteVersion is the version reported by sqlite_version()
+	SQLiteVersion string
+	// HasTrackingTable is false if no migrations table exists yet
+	HasTrackingTable bool
+	// CurrentVersion is the latest applied migration version
+	CurrentVersion string
+}
+
+// Info queries identifying information about the connected database using
+// read-only statements
+func (s *TursoStorage) Info() (DatabaseInfo, error) {
+	info := DatabaseInfo{URL: s.url}
+	info.Host, info.Remote = s.host()
+
+	if err := s.db.QueryRow(s.dialect.ServerVersion()).Scan(&info.SQLiteVersion); err != nil {
+		return info, err
+	}
+
+	exists, err := s.hasTrackingTable()
+	if err != nil {
+		return info, err
+	}
+	info.HasTrackingTable = exists
+
+	if info.HasTrackingTable {
+		version, err := s.GetCurrentVersion()
+		if err != nil {
+			return info, err
+		}
+		info.CurrentVersion = version
+	}
+
+	return info, nil
+}
+
+// host returns the database host, or the file path of a local database,
+// and whether the database is remote
+func (s *TursoStorage) host() (host string, remote bool) {
+	u, err := url.Parse(s.url)
+	if err != nil {
+		return "", false
+	}
+	if u.Scheme == "file" {
+		return strings.TrimPrefix(s.url, "file:"), false
+	}
+	return u.Host, true
+}
+
+// hasTrackingTable reports whether the tracking table exists
+func (s *TursoStorage) hasTrackingTable() (bool, error) {
+	var count int
+	err := s.db.QueryRow(s.dialect.TableExists(s.schema), s.table).Scan(&count)
+	return count > 0, err
+}
+
+// authTokenParam matches an authToken query parameter and its value
+var authTokenParam = regexp.MustCompile(`(authToken=)[^&\s"']*`)
+
+// redactURL masks the authToken query parameter of a connection string,
+// or of any URL within a longer message such as an error
+func redactURL(connStr string) string {
+	return authTokenParam.ReplaceAllString(connStr, "${1}REDACTED")
+}
+
+// redactedError is an error whose message had auth tokens masked; it still
+// unwraps to the original error so errors.Is and errors.As keep working
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
+
+// Redact masks auth tokens in msg, both as URL parameters and wherever
+// token itself appears
+func Redact(msg, token string) string {
+	msg = redactURL(msg)
+	if token != "" {
+		msg = strings.ReplaceAll(msg, token, "REDACTED")
+	}
+	return msg
+}
+
+// RedactError applies Redact to err's message; the error returned still
+// unwraps to err. Errors of this package and of the database driver may
+// include the connection string, so callers apply it to every error they
+// show.
+func RedactError(err error, token string) error {
+	if err == nil {
+		return nil
+	}
+	msg := Redact(err.Error(), token)
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{msg: msg, err: err}
+}
@@ -0,0 +1,21 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRedactError(t *testing.T) {
+	base := errors.New("connecting to libsql://db.turso.io?authToken=abc.def: token abc.def rejected")
+	err := RedactError(base, "abc.def")
+	if strings.Contains(err.Error(), "abc.def") {
+		t.Errorf("RedactError left the token in %q", err)
+	}
+	if !errors.Is(err, base) {
+		t.Error("RedactError lost the original error")
+	}
+	if RedactError(nil, "abc.def") != nil {
+		t.Error("RedactError(nil) is not nil")
+	}
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// DefaultLockSuffix is appended to the tracking table name to form the
+// default lock table name
+const DefaultLockSuffix = "_lock"
+
+// ErrLocked is returned by AcquireLock when another process holds the lock
+// for longer than the lock timeout
+var ErrLocked = errors.New("migrations are locked by another process")
+
+// ErrNotLocked is returned by LockHolder when no process holds the lock
+var ErrNotLocked = errors.New("migrations are not locked")
+
+// AcquireLock takes the migration lock by inserting the single row of the
+// lock table, retrying every lock retry interval until the lock timeout
+// passes. A zero timeout tries once. A lock older than the stale lock age
+// is removed first, so a crashed process doesn't block later runs forever.
+func (s *TursoStorage) AcquireLock() error {
+	return s.AcquireLockContext(context.Background())
+}
+
+// AcquireLockContext is AcquireLock with a context; it stops waiting for
+// the lock once ctx is done
+func (s *TursoStorage) AcquireLockContext(ctx context.Context) error {
+	if err := s.createTable(ctx, s.dialect.CreateLockTable(s.qualifiedLockTable())); err != nil {
+		return err
+	}
+
+	owner := lockOwner()
+	deadline := time.Now().Add(s.lockTimeout)
+
+	for {
+		if s.lockStaleAfter > 0 {
+			cutoff := time.Now().Add(-s.lockStaleAfter)
+			if _, err := s.db.ExecContext(ctx, s.dialect.DeleteStaleLock(s.qualifiedLockTable()), cutoff); err != nil {
+				return err
+			}
+		}
+
+		result, err := s.db.ExecContext(ctx, s.dialect.InsertLock(s.qualifiedLockTable()), owner, time.Now())
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n > 0 {
+			s.startHeartbeat(owner)
+			return nil
+		}
+
+		if !time.Now().Before(deadline) {
+			holder, lockedAt, err := s.LockHolder()
+			if err != nil {
+				return ErrLocked
+			}
+			return fmt.Errorf("%w: held by %s since %s", ErrLocked, holder, lockedAt.Format(time.RFC3339))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.lockRetryInterval):
+		}
+	}
+}
+
+// startHeartbeat refreshes the time of the lock held by owner every third
+// of the stale lock age, until ReleaseLock, so that other processes don't
+// remove the lock of a run that is merely long. Without a stale lock age
+// locks are never removed, so there is nothing to refresh.
+func (s *TursoStorage) startHeartbeat(owner string) {
+	if s.lockStaleAfter <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(s.lockStaleAfter / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// A failed refresh is retried on the next tick, well
+				// before the lock could be taken for a stale one
+				s.db.ExecContext(ctx, s.dialect.RefreshLock(s.qualifiedLockTable()), time.Now(), owner)
+			}
+		}
+	}()
+	s.stopHeartbeat = func() {
+		cancel()
+		<-done
+	}
+}
+
+// ReleaseLock releases the migration lock taken by this process. A lock
+// since taken over by another process, after this one was assumed to have
+// crashed, is left alone.
+func (s *TursoStorage) ReleaseLock() error {
+	if s.stopHeartbeat != nil {
+		s.stopHeartbeat()
+		s.stopHeartbeat = nil
+	}
+	_, err := s.db.Exec(s.dialect.DeleteOwnLock(s.qualifiedLockTable()), lockOwner())
+	return err
+}
+
+// ForceReleaseLock removes the migration lock, whichever process holds it
+func (s *TursoStorage) ForceReleaseLock() error {
+	_, err := s.db.Exec(s.dialect.DeleteLock(s.qualifiedLockTable()))
+	return err
+}
+
+// LockHolder returns the owner of the current lock, as host:pid, and when
+// it was taken, or ErrNotLocked if the lock is free
+func (s *TursoStorage) LockHolder() (string, time.Time, error) {
+	var count int
+	if err := s.db.QueryRow(s.dialect.TableExists(s.schema), s.lockTable).Scan(&count); err != nil {
+		return "", time.Time{}, err
+	}
+	if count == 0 {
+		return "", time.Time{}, ErrNotLocked
+	}
+
+	var owner string
+	var lockedAt time.Time
+	err := s.db.QueryRow(s.dialect.SelectLock(s.qualifiedLockTable())).Scan(&owner, &lockedAt)
+	if err == sql.ErrNoRows {
+		return "", time.Time{}, ErrNotLocked
+	}
+	return owner, lockedAt, err
+}
+
+// qualifiedLockTable returns the quoted lock table name qualified by the
+// tracking table's schema
+func (s *TursoStorage) qualifiedLockTable() string {
+	return s.dialect.QuoteIdentifier(s.schema) + "." + s.dialect.QuoteIdentifier(s.lockTable)
+}
+
+// lockOwner identifies this process in the lock table
+func lockOwner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
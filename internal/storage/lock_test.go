@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockHeartbeatKeepsLongRunLocked(t *testing.T) {
+	url := "file:" + filepath.Join(t.TempDir(), "lock.db") + "?_pragma=busy_timeout(5000)"
+	opts := Options{LockStaleAfter: 150 * time.Millisecond}
+
+	first, err := NewWithOptions(url, "", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	second, err := NewWithOptions(url, "", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+
+	if err := first.AcquireLock(); err != nil {
+		t.Fatalf("first AcquireLock: %v", err)
+	}
+
+	// Well past the stale age, the refreshed lock must still hold
+	time.Sleep(400 * time.Millisecond)
+	if err := second.AcquireLock(); !errors.Is(err, ErrLocked) {
+		t.Fatalf("second AcquireLock during a long run = %v, want ErrLocked", err)
+	}
+
+	if err := first.ReleaseLock(); err != nil {
+		t.Fatalf("ReleaseLock: %v", err)
+	}
+	if err := second.AcquireLock(); err != nil {
+		t.Fatalf("second AcquireLock after release: %v", err)
+	}
+	if err := second.ReleaseLock(); err != nil {
+		t.Fatalf("second ReleaseLock: %v", err)
+	}
+}
+
+func TestReleaseLockKeepsOtherOwnersLock(t *testing.T) {
+	s, _ := openTemp(t, Options{})
+	if err := s.AcquireLock(); err != nil {
+		t.Fatal(err)
+	}
+	// Simulate another process having taken the lock over
+	if _, err := s.db.Exec(`UPDATE `+s.qualifiedLockTable()+` SET owner = ?`, "other-host:1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.ReleaseLock(); err != nil {
+		t.Fatalf("ReleaseLock: %v", err)
+	}
+	owner, _, err := s.LockHolder()
+	if err != nil || owner != "other-host:1" {
+		t.Fatalf("LockHolder() = %q, %v; want the other owner's lock kept", owner, err)
+	}
+
+	if err := s.ForceReleaseLock(); err != nil {
+		t.Fatalf("ForceReleaseLock: %v", err)
+	}
+	if _, _, err := s.LockHolder(); !errors.Is(err, ErrNotLocked) {
+		t.Fatalf("LockHolder() after ForceReleaseLock = %v, want ErrNotLocked", err)
+	}
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"regexp"
+	"syscall"
+	"time"
+)
+
+// transientStatusPattern matches gateway errors reported by the libSQL client
+var transientStatusPattern = regexp.MustCompile(`error code 50[234]\b`)
+
+// conflictPattern matches the errors SQLite and libSQL report when another
+// connection holds a lock or changed the schema at the same moment
+var conflictPattern = regexp.MustCompile(`(?i)database is locked|database table is locked|SQLITE_BUSY|SQLITE_LOCKED|already exists|schema has changed`)
+
+// isConflict reports whether err comes from another connection changing
+// the database at the same moment, so the same statement may succeed when
+// repeated
+func isConflict(err error) bool {
+	return err != nil && conflictPattern.MatchString(err.Error())
+}
+
+// isTransient reports whether err looks like a transient network failure.
+// SQL, syntax and constraint errors are never transient.
+func isTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	return transientStatusPattern.MatchString(err.Error())
+}
+
+// withRetry runs fn, retrying transient failures up to s.execRetries times
+// with exponential backoff starting at s.execRetryDelay, until ctx is done
+func (s *TursoStorage) withRetry(ctx context.Context, fn func() error) error {
+	delay := s.execRetryDelay
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= s.execRetries || !isTransient(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTooManyRows is returned by DumpRows when a table has more rows than
+// the given limit
+var ErrTooManyRows = errors.New("table has too many rows")
+
+// DumpRows returns the column names and rows of table, failing with
+// ErrTooManyRows if it has more than limit rows
+func (s *TursoStorage) DumpRows(table string, limit int) ([]string, [][]any, error) {
+	if !identifierPattern.MatchString(table) {
+		return nil, nil, fmt.Errorf("invalid table name: %q", table)
+	}
+
+	query := fmt.Sprintf(`SELECT * FROM %s LIMIT ?`, s.dialect.QuoteIdentifier(table))
+	rows, err := s.db.Query(query, limit+1)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var values [][]any
+	for rows.Next() {
+		if len(values) == limit {
+			return nil, nil, fmt.Errorf("%w: %s has more than %d rows", ErrTooManyRows, table, limit)
+		}
+
+		row := make([]any, len(columns))
+		dest := make([]any, len(columns))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, nil, err
+		}
+		values = append(values, row)
+	}
+
+	return columns, values, rows.Err()
+}
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// statementScanner reads SQL statements one at a time from a reader.
+// Semicolons inside string literals, quoted identifiers, comments,
+// trigger BEGIN...END bodies and goose or sql-migrate
+// "-- +goose StatementBegin" ... "-- +goose StatementEnd" blocks do not end
+// a statement.
+type statementScanner struct {
+	r    *bufio.Reader
+	stmt string
+	err  error
+}
+
+// SplitStatements splits a SQL script into its individual statements
+func SplitStatements(sql string) []string {
+	var statements []string
+	scanner := newStatementScanner(strings.NewReader(sql))
+	for scanner.Scan() {
+		statements = append(statements, scanner.Statement())
+	}
+	return statements
+}
+
+// newStatementScanner creates a statementScanner reading from r
+func newStatementScanner(r io.Reader) *statementScanner {
+	return &statementScanner{r: bufio.NewReader(r)}
+}
+
+// Statement returns the statement read by the last call to Scan
+func (s *statementScanner) Statement() string {
+	return s.stmt
+}
+
+// Err returns the first non-EOF error encountered by Scan
+func (s *statementScanner) Err() error {
+	return s.err
+}
+
+// Scan advances to the next non-empty statement, returning false at the
+// end of input or on error
+func (s *statementScanner) Scan() bool {
+	var (
+		buf        strings.Builder
+		word       strings.Builder
+		first      string
+		words      int
+		isTrigger  bool
+		depth      int
+		inBlock    bool
+		hasContent bool
+	)
+
+	flushWord := func() {
+		if word.Len() == 0 {
+			return
+		}
+		w := strings.ToUpper(word.String())
+		word.Reset()
+
+		words++
+		if words == 1 {
+			first = w
+		}
+		if first == "CREATE" && w == "TRIGGER" && words <= 3 {
+			isTrigger = true
+		}
+
+		switch w {
+		case "BEGIN":
+			if isTrigger {
+				depth++
+			}
+		case "CASE":
+			depth++
+		case "END":
+			if depth > 0 {
+				depth--
+			}
+		}
+	}
+
+	for {
+		r, _, err := s.r.ReadRune()
+		if err != nil {
+			if err != io.EOF {
+				s.err = err
+				return false
+			}
+			if hasContent {
+				s.stmt = strings.TrimSpace(buf.String())
+				return true
+			}
+			return false
+		}
+
+		switch {
+		case r == '\'' || r == '"' || r == '`' || r == '[':
+			flushWord()
+			hasContent = true
+			closing := r
+			if r == '[' {
+				closing = ']'
+			}
+			buf.WriteRune(r)
+			if err := s.copyUntil(&buf, string(closing)); err != nil && err != io.EOF {
+				s.err = err
+				return false
+			}
+
+		case r == '-' && s.peek('-'):
+			flushWord()
+			s.r.ReadRune()
+			start := buf.Len()
+			buf.WriteString("--")
+			if err := s.copyUntil(&buf, "\n"); err != nil && err != io.EOF {
+				s.err = err
+				return false
+			}
+
+			switch strings.TrimSpace(buf.String()[start:]) {
+			case "-- +goose StatementBegin", "-- +migrate StatementBegin":
+				inBlock = true
+			case "-- +goose StatementEnd", "-- +migrate StatementEnd":
+				inBlock = false
+				if hasContent {
+					s.stmt = strings.TrimSpace(buf.String())
+					return true
+				}
+			}
+
+		case r == '/' && s.peek('*'):
+			flushWord()
+			s.r.ReadRune()
+			buf.WriteString("/*")
+			if err := s.copyUntil(&buf, "*/"); err != nil && err != io.EOF {
+				s.err = err
+				return false
+			}
+
+		case r == ';':
+			flushWord()
+			if depth > 0 || inBlock {
+				buf.WriteRune(r)
+				continue
+			}
+			if hasContent {
+				s.stmt = strings.TrimSpace(buf.String())
+				return true
+			}
+			// Only whitespace and comments so far; start over
+			buf.Reset()
+			first, words, isTrigger = "", 0, false
+
+		case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			hasContent = true
+			word.WriteRune(r)
+			buf.WriteRune(r)
+
+		default:
+			flushWord()
+			if !unicode.IsSpace(r) {
+				hasContent = true
+			}
+			buf.WriteRune(r)
+		}
+	}
+}
+
+// peek reports whether the next rune is r without consuming it
+func (s *statementScanner) peek(r rune) bool {
+	next, _, err := s.r.ReadRune()
+	if err != nil {
+		return false
+	}
+	s.r.UnreadRune()
+	return next == r
+}
+
+// copyUntil copies runes into buf up to and including the terminator
+func (s *statementScanner) copyUntil(buf *strings.Builder, terminator string) error {
+	for {
+		r, _, err := s.r.ReadRune()
+		if err != nil {
+			return err
+		}
+		buf.WriteRune(r)
+		if strings.HasSuffix(buf.String(), terminator) {
+			return nil
+		}
+	}
+}
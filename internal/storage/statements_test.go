@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{
+			name: "semicolon in string literal",
+			sql:  "INSERT INTO t VALUES ('a;b');\nSELECT 1;",
+			want: []string{"INSERT INTO t VALUES ('a;b')", "SELECT 1"},
+		},
+		{
+			name: "semicolon in quoted identifier",
+			sql:  `SELECT "a;b" FROM t; SELECT 2;`,
+			want: []string{`SELECT "a;b" FROM t`, "SELECT 2"},
+		},
+		{
+			name: "semicolon in line comment",
+			sql:  "SELECT 1; -- done; really\nSELECT 2;",
+			want: []string{"SELECT 1", "-- done; really\nSELECT 2"},
+		},
+		{
+			name: "semicolon in block comment",
+			sql:  "SELECT 1; /* c; d */ SELECT 2;",
+			want: []string{"SELECT 1", "/* c; d */ SELECT 2"},
+		},
+		{
+			name: "trigger body",
+			sql:  "CREATE TRIGGER tr AFTER INSERT ON t BEGIN\n  UPDATE t SET a = 1;\n  DELETE FROM u;\nEND;\nSELECT 1;",
+			want: []string{"CREATE TRIGGER tr AFTER INSERT ON t BEGIN\n  UPDATE t SET a = 1;\n  DELETE FROM u;\nEND", "SELECT 1"},
+		},
+		{
+			name: "CASE END inside trigger body",
+			sql:  "CREATE TRIGGER tr AFTER INSERT ON t BEGIN\n  UPDATE t SET a = CASE WHEN new.a > 0 THEN 1 ELSE 0 END;\nEND;\nSELECT 1;",
+			want: []string{"CREATE TRIGGER tr AFTER INSERT ON t BEGIN\n  UPDATE t SET a = CASE WHEN new.a > 0 THEN 1 ELSE 0 END;\nEND", "SELECT 1"},
+		},
+		{
+			name: "CASE END outside a trigger",
+			sql:  "SELECT CASE WHEN 1 THEN 'x' END; SELECT 2",
+			want: []string{"SELECT CASE WHEN 1 THEN 'x' END", "SELECT 2"},
+		},
+		{
+			name: "goose statement block",
+			sql:  "-- +goose StatementBegin\nCREATE TABLE a (x);\nINSERT INTO a VALUES (1);\n-- +goose StatementEnd\nSELECT 1;",
+			want: []string{"-- +goose StatementBegin\nCREATE TABLE a (x);\nINSERT INTO a VALUES (1);\n-- +goose StatementEnd", "SELECT 1"},
+		},
+		{
+			name: "sql-migrate statement block",
+			sql:  "-- +migrate StatementBegin\nCREATE TABLE a (x);\nINSERT INTO a VALUES (1);\n-- +migrate StatementEnd\nSELECT 1;",
+			want: []string{"-- +migrate StatementBegin\nCREATE TABLE a (x);\nINSERT INTO a VALUES (1);\n-- +migrate StatementEnd", "SELECT 1"},
+		},
+		{
+			name: "empty statements",
+			sql:  ";;\n  ;",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SplitStatements(tt.sql); !slices.Equal(got, tt.want) {
+				t.Errorf("SplitStatements(%q) = %q, want %q", tt.sql, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// syntaxErrorPattern matches the errors SQLite reports for statements that
+// fail to parse, as opposed to ones that refer to missing tables or columns
+var syntaxErrorPattern = regexp.MustCompile(`(?i)syntax error|incomplete input|unrecognized token`)
+
+// CheckSyntax runs EXPLAIN on each statement read from r, which compiles
+// it without executing it, and returns an error for the first statement
+// that fails to parse. Other errors, such as a table created by an earlier
+// statement not existing yet, are ignored since nothing has run.
+func (s *TursoStorage) CheckSyntax(r io.Reader) error {
+	return s.CheckSyntaxContext(context.Background(), r)
+}
+
+// CheckSyntaxContext is CheckSyntax with a context
+func (s *TursoStorage) CheckSyntaxContext(ctx context.Context, r io.Reader) error {
+	scanner := newStatementScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		stmt := scanner.Statement()
+		if strings.HasPrefix(strings.ToUpper(stripLeadingComments(stmt)), "EXPLAIN") {
+			continue
+		}
+
+		err := s.explain(ctx, stmt)
+		if err != nil && syntaxErrorPattern.MatchString(err.Error()) {
+			return fmt.Errorf("statement %d: %w", n, err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return scanner.Err()
+}
+
+// explain compiles stmt with EXPLAIN, discarding the program it returns
+func (s *TursoStorage) explain(ctx context.Context, stmt string) error {
+	rows, err := s.db.QueryContext(ctx, "EXPLAIN "+stmt)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+// stripLeadingComments removes whitespace and "--" comment lines from the
+// start of stmt
+func stripLeadingComments(stmt string) string {
+	for {
+		stmt = strings.TrimSpace(stmt)
+		if !strings.HasPrefix(stmt, "--") {
+			return stmt
+		}
+		_, rest, _ := strings.Cut(stmt, "\n")
+		stmt = rest
+	}
+}
@@ -1,16 +1,87 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	_ "github.com/tursodatabase/libsql-client-go/libsql"
+	// The libSQL client opens file: URLs through a registered "sqlite"
+	// driver; this one is pure Go, so builds need no cgo
+	_ "modernc.org/sqlite"
 )
 
+// DefaultTable is the default name of the migration tracking table
+const DefaultTable = "schema_migrations"
+
+// trackingAlias is the schema name a separate tracking database is attached as
+const trackingAlias = "tracking"
+
+// identifierPattern matches identifiers that are safe to use unquoted
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 // TursoStorage handles database operations for Turso migrations
 type TursoStorage struct {
-	db *sql.DB
+	db                *sql.DB
+	url               string
+	dialect           Dialect
+	schema            string
+	table             string
+	lockTable         string
+	lockTimeout       time.Duration
+	lockRetryInterval time.Duration
+	lockStaleAfter    time.Duration
+	// stopHeartbeat stops refreshing the lock while it is held
+	stopHeartbeat  func()
+	execRetries    int
+	execRetryDelay time.Duration
+	initialized    bool
+	logStatement   func(stmt string)
+	// pinned is the connection set by PinConnContext, if any
+	pinned *sql.Conn
+}
+
+// Options configures a TursoStorage
+type Options struct {
+	// Table is the name of the migration tracking table
+	Table string
+	// LockTable is the name of the migration lock table, defaulting to the
+	// tracking table name followed by DefaultLockSuffix
+	LockTable string
+	// LockTimeout is how long AcquireLock waits for another process to
+	// release the lock
+	LockTimeout time.Duration
+	// LockRetryInterval is the delay between attempts to take the lock,
+	// defaulting to one second
+	LockRetryInterval time.Duration
+	// LockStaleAfter is the age after which a lock is assumed to be left
+	// by a crashed process and removed. Zero, the default, never removes a
+	// lock. While a process holds the lock it refreshes its time, so a
+	// long run is never taken for a crashed one.
+	LockStaleAfter time.Duration
+	// ExecRetries is how many times connecting and ExecuteSQL retry
+	// transient network errors. Retrying can re-run a migration whose
+	// commit succeeded but whose response was lost, so it defaults to 0.
+	ExecRetries int
+	// ExecRetryDelay is the delay before the first retry, doubling after each
+	ExecRetryDelay time.Duration
+	// Dialect generates the SQL for the tracking table, defaulting to
+	// SQLiteDialect
+	Dialect Dialect
+	// TrackingDB is a database URL or file attached to hold the tracking
+	// table, keeping it out of the main database's schema
+	TrackingDB string
+	// SkipInitSchema leaves the tracking table untouched so that read-only
+	// credentials can connect
+	SkipInitSchema bool
+	// Namespace, if set, keeps an independent migration history in the
+	// tracking table named Table followed by an underscore and Namespace
+	Namespace string
 }
 
 // Migration represents a single migration record
@@ -18,70 +89,398 @@ type Migration struct {
 	Version   string
 	Name      string
 	AppliedAt time.Time
+	// RunID identifies the up run that applied the migration; it is empty
+	// for migrations recorded before run IDs were tracked
+	RunID string
+	// Checksum is the SHA-256 of the UP SQL when it was applied; it is
+	// empty for migrations recorded before checksums were tracked
+	Checksum string
+	// Duration is how long the UP SQL took to execute, with millisecond
+	// precision; it is zero for migrations recorded before durations were
+	// tracked
+	Duration time.Duration
+	// Note is the "-- Note:" header of the migration file when it was
+	// applied, if any
+	Note string
+}
+
+// IsLocalURL reports whether databaseURL names a local SQLite database, a
+// file: URL or :memory:, rather than a Turso server. Local databases are
+// opened through a registered sqlite or sqlite3 driver without an auth
+// token.
+func IsLocalURL(databaseURL string) bool {
+	return databaseURL == ":memory:" || strings.HasPrefix(databaseURL, "file:")
+}
+
+// localBusyTimeout is how long a local database waits for another
+// connection's lock, such as another process creating the tracking table,
+// before failing with SQLITE_BUSY
+const localBusyTimeout = "_pragma=busy_timeout(5000)"
+
+// localConnString returns the connection string of a local database
+func localConnString(databaseURL string) string {
+	if databaseURL == ":memory:" {
+		return "file::memory:"
+	}
+	return databaseURL
+}
+
+// withBusyTimeout adds localBusyTimeout to the connection string of a
+// local database, unless it sets a busy timeout itself
+func withBusyTimeout(connStr string) string {
+	switch {
+	case strings.Contains(connStr, "busy_timeout"):
+		return connStr
+	case strings.Contains(connStr, "?"):
+		return connStr + "&" + localBusyTimeout
+	default:
+		return connStr + "?" + localBusyTimeout
+	}
 }
 
 // New creates a new TursoStorage instance
 func New(databaseURL, authToken string) (*TursoStorage, error) {
-	// Construct the connection string with auth token
+	return NewWithOptions(databaseURL, authToken, Options{})
+}
+
+// NewWithOptions creates a new TursoStorage instance configured by opts
+func NewWithOptions(databaseURL, authToken string, opts Options) (*TursoStorage, error) {
+	if opts.Table == "" {
+		opts.Table = DefaultTable
+	}
+	if opts.Namespace != "" {
+		opts.Table += "_" + opts.Namespace
+	}
+	if !identifierPattern.MatchString(opts.Table) {
+		return nil, fmt.Errorf("invalid tracking table name: %q", opts.Table)
+	}
+	if opts.LockTable == "" {
+		opts.LockTable = opts.Table + DefaultLockSuffix
+	}
+	if !identifierPattern.MatchString(opts.LockTable) {
+		return nil, fmt.Errorf("invalid lock table name: %q", opts.LockTable)
+	}
+	if opts.LockRetryInterval <= 0 {
+		opts.LockRetryInterval = time.Second
+	}
+	if opts.Dialect == nil {
+		opts.Dialect = SQLiteDialect{}
+	}
+
+	// Construct the connection string with auth token; local databases
+	// don't take one
 	connStr := databaseURL
-	if authToken != "" {
+	local := IsLocalURL(databaseURL)
+	if local {
+		connStr = localConnString(databaseURL)
+	} else if authToken != "" {
 		connStr = fmt.Sprintf("%s?authToken=%s", databaseURL, authToken)
 	}
 
+	shownURL := redactURL(connStr)
+	if local {
+		connStr = withBusyTimeout(connStr)
+	}
+
 	db, err := sql.Open("libsql", connStr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	if local {
+		// An in-memory database exists only on the connection that opened
+		// it, so keep everything on one
+		db.SetMaxOpenConns(1)
+	}
 
-	storage := &TursoStorage{db: db}
+	storage := &TursoStorage{
+		db:                db,
+		url:               shownURL,
+		dialect:           opts.Dialect,
+		schema:            "main",
+		table:             opts.Table,
+		lockTable:         opts.LockTable,
+		lockTimeout:       opts.LockTimeout,
+		lockRetryInterval: opts.LockRetryInterval,
+		lockStaleAfter:    opts.LockStaleAfter,
+		execRetries:       opts.ExecRetries,
+		execRetryDelay:    opts.ExecRetryDelay,
+	}
 
-	// Initialize schema migrations table
-	if err := storage.InitSchema(); err != nil {
-		return nil, fmt.Errorf("failed to initialize schema: %w", err)
+	// sql.Open doesn't connect, so ping to report a bad URL or token
+	// clearly before anything else runs
+	if err := storage.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if opts.TrackingDB != "" {
+		// ATTACH only applies to the connection it runs on, so keep
+		// everything on a single connection
+		db.SetMaxOpenConns(1)
+		if _, err := db.Exec(opts.Dialect.AttachDatabase(trackingAlias), opts.TrackingDB); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to attach tracking database: %w", err)
+		}
+		storage.schema = trackingAlias
+	}
+
+	if opts.SkipInitSchema {
+		return storage, nil
+	}
+
+	// Initialize schema migrations table, retrying since it is safe to
+	// repeat
+	if err := storage.withRetry(context.Background(), storage.InitSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize schema: %w", classifyError(err))
 	}
 
 	return storage, nil
 }
 
-// InitSchema creates the schema_migrations table if it doesn't exist
+// InitSchema creates the tracking table if it doesn't exist and
+// adds any columns missing from tables created by older versions. It is
+// safe to run from several processes at once: losing a race to create the
+// table or add a column is not an error.
 func (s *TursoStorage) InitSchema() error {
-	query := `
-		CREATE TABLE IF NOT EXISTS schema_migrations (
-			version TEXT PRIMARY KEY,
-			name TEXT NOT NULL,
-			applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
-		)
-	`
-	_, err := s.db.Exec(query)
+	if err := s.createTable(context.Background(), s.dialect.CreateTrackingTable(s.qualifiedTable())); err != nil {
+		return err
+	}
+
+	for _, column := range s.dialect.AddedColumns() {
+		if err := s.ensureColumn(s.table, column.Name, column.Definition); err != nil {
+			return err
+		}
+	}
+
+	exists, err := s.hasTrackingTable()
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("tracking table %s was not created", s.table)
+	}
+
+	s.initialized = true
+	return nil
+}
+
+// createTableRetries is how many times createTable retries a conflict,
+// waiting createTableRetryDelay, doubled each time, in between
+const (
+	createTableRetries    = 3
+	createTableRetryDelay = 50 * time.Millisecond
+)
+
+// createTable runs a CREATE TABLE IF NOT EXISTS statement. When another
+// process creates the same table at the same moment libSQL can still
+// report a conflict or a busy database, so those errors are retried after
+// a short wait, by which time the table exists and the statement does
+// nothing. Any other error fails at once.
+func (s *TursoStorage) createTable(ctx context.Context, stmt string) error {
+	delay := createTableRetryDelay
+	for attempt := 0; ; attempt++ {
+		_, err := s.db.ExecContext(ctx, stmt)
+		if err == nil || attempt >= createTableRetries || !isConflict(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// readTable returns the tracking table for queries that only read it.
+// Without InitSchema, as in read-only mode and dry runs, a table created
+// by an older version may lack columns added since; those are then read
+// as NULL through the dialect's PadColumns instead of being added.
+func (s *TursoStorage) readTable() (string, error) {
+	if s.initialized {
+		return s.qualifiedTable(), nil
+	}
+
+	var missing []string
+	for _, column := range s.dialect.AddedColumns() {
+		exists, err := s.hasColumn(s.table, column.Name)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			missing = append(missing, column.Name)
+		}
+	}
+	if len(missing) == 0 {
+		return s.qualifiedTable(), nil
+	}
+	return s.dialect.PadColumns(s.qualifiedTable(), missing), nil
+}
+
+// trackingTableMissing reports whether the tracking table doesn't exist,
+// which is only possible when InitSchema was skipped
+func (s *TursoStorage) trackingTableMissing() (bool, error) {
+	if s.initialized {
+		return false, nil
+	}
+	exists, err := s.hasTrackingTable()
+	return !exists, err
+}
+
+// hasColumn reports whether table has the named column
+func (s *TursoStorage) hasColumn(table, column string) (bool, error) {
+	var count int
+	err := s.db.QueryRow(s.dialect.ColumnExists(), table, s.schema, column).Scan(&count)
+	return count > 0, err
+}
+
+// ensureColumn adds a column to table if it doesn't already exist
+func (s *TursoStorage) ensureColumn(table, column, definition string) error {
+	if exists, err := s.hasColumn(table, column); err != nil || exists {
+		return err
+	}
+
+	qualified := s.dialect.QuoteIdentifier(s.schema) + "." + s.dialect.QuoteIdentifier(table)
+	_, err := s.db.Exec(s.dialect.AddColumn(qualified, column, definition))
+	if err == nil {
+		return nil
+	}
+
+	// Another process may have added the column since it was checked
+	if exists, checkErr := s.hasColumn(table, column); checkErr == nil && exists {
+		return nil
+	}
 	return err
 }
 
-// RecordMigration records a migration as applied
-func (s *TursoStorage) RecordMigration(version, name string) error {
-	query := `
-		INSERT INTO schema_migrations (version, name, applied_at)
-		VALUES (?, ?, ?)
-	`
-	_, err := s.db.Exec(query, version, name, time.Now())
+// RecordMigration records a migration as applied by the run runID with the
+// checksum of its UP SQL, its note and how long it took, clearing any
+// earlier soft rollback of the same version
+func (s *TursoStorage) RecordMigration(version, name, runID, checksum, note string, duration time.Duration) error {
+	return s.RecordMigrationContext(context.Background(), version, name, runID, checksum, note, duration)
+}
+
+// RecordMigrationContext is RecordMigration with a context
+func (s *TursoStorage) RecordMigrationContext(ctx context.Context, version, name, runID, checksum, note string, duration time.Duration) error {
+	_, err := s.conn().ExecContext(ctx, s.dialect.RecordMigration(s.qualifiedTable()), version, name, time.Now(), runID, checksum, note, duration.Milliseconds())
+	return err
+}
+
+// RecordMigrationTx is RecordMigration within the transaction tx
+func (s *TursoStorage) RecordMigrationTx(tx *sql.Tx, version, name, runID, checksum, note string, duration time.Duration) error {
+	return s.RecordMigrationTxContext(context.Background(), tx, version, name, runID, checksum, note, duration)
+}
+
+// RecordMigrationTxContext is RecordMigrationTx with a context
+func (s *TursoStorage) RecordMigrationTxContext(ctx context.Context, tx *sql.Tx, version, name, runID, checksum, note string, duration time.Duration) error {
+	_, err := tx.ExecContext(ctx, s.dialect.RecordMigration(s.qualifiedTable()), version, name, time.Now(), runID, checksum, note, duration.Milliseconds())
 	return err
 }
 
 // RemoveMigration removes a migration record
 func (s *TursoStorage) RemoveMigration(version string) error {
-	query := `DELETE FROM schema_migrations WHERE version = ?`
-	_, err := s.db.Exec(query, version)
+	return s.RemoveMigrationContext(context.Background(), version)
+}
+
+// RemoveMigrationContext is RemoveMigration with a context
+func (s *TursoStorage) RemoveMigrationContext(ctx context.Context, version string) error {
+	_, err := s.conn().ExecContext(ctx, s.dialect.RemoveMigration(s.qualifiedTable()), version)
+	return err
+}
+
+// DropTrackingTable drops the migrations table with every record in it.
+// It is created again by the next InitSchema.
+func (s *TursoStorage) DropTrackingTable() error {
+	return s.DropTrackingTableContext(context.Background())
+}
+
+// DropTrackingTableContext is DropTrackingTable with a context
+func (s *TursoStorage) DropTrackingTableContext(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, s.dialect.DropTable(s.qualifiedTable())); err != nil {
+		return err
+	}
+	s.initialized = false
+	return nil
+}
+
+// MarkRolledBack marks a migration record as rolled back, keeping the row
+// so the history shows it was applied and later reverted
+func (s *TursoStorage) MarkRolledBack(version string) error {
+	return s.MarkRolledBackContext(context.Background(), version)
+}
+
+// MarkRolledBackContext is MarkRolledBack with a context
+func (s *TursoStorage) MarkRolledBackContext(ctx context.Context, version string) error {
+	_, err := s.conn().ExecContext(ctx, s.dialect.MarkRolledBack(s.qualifiedTable()), time.Now(), version)
 	return err
 }
 
 // GetAppliedMigrations returns all applied migrations ordered by version
 func (s *TursoStorage) GetAppliedMigrations() ([]Migration, error) {
-	query := `
-		SELECT version, name, applied_at 
-		FROM schema_migrations 
-		ORDER BY version ASC
-	`
+	return s.GetAppliedMigrationsContext(context.Background())
+}
+
+// GetAppliedMigrationsContext is GetAppliedMigrations with a context
+func (s *TursoStorage) GetAppliedMigrationsContext(ctx context.Context) ([]Migration, error) {
+	if missing, err := s.trackingTableMissing(); err != nil || missing {
+		return nil, err
+	}
+
+	table, err := s.readTable()
+	if err != nil {
+		return nil, err
+	}
+	return s.queryApplied(ctx, s.dialect.SelectApplied(table))
+}
+
+// GetAppliedMigrationsRange returns the applied migrations with versions
+// from from to to inclusive, compared numerically and ordered by version.
+// An empty bound is open, so GetAppliedMigrationsRange("", "") returns
+// them all.
+func (s *TursoStorage) GetAppliedMigrationsRange(from, to string) ([]Migration, error) {
+	return s.GetAppliedMigrationsRangeContext(context.Background(), from, to)
+}
+
+// GetAppliedMigrationsRangeContext is GetAppliedMigrationsRange with a context
+func (s *TursoStorage) GetAppliedMigrationsRangeContext(ctx context.Context, from, to string) ([]Migration, error) {
+	if missing, err := s.trackingTableMissing(); err != nil || missing {
+		return nil, err
+	}
+	table, err := s.readTable()
+	if err != nil {
+		return nil, err
+	}
+	return s.queryApplied(ctx, s.dialect.SelectAppliedRange(table), from, from, to, to)
+}
+
+// GetLastApplied returns the n applied migrations with the highest
+// versions, or all of them if fewer are applied, ordered by version
+func (s *TursoStorage) GetLastApplied(n int) ([]Migration, error) {
+	return s.GetLastAppliedContext(context.Background(), n)
+}
+
+// GetLastAppliedContext is GetLastApplied with a context
+func (s *TursoStorage) GetLastAppliedContext(ctx context.Context, n int) ([]Migration, error) {
+	if missing, err := s.trackingTableMissing(); err != nil || missing {
+		return nil, err
+	}
+	table, err := s.readTable()
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := s.queryApplied(ctx, s.dialect.SelectLastApplied(table), n)
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(migrations)
+	return migrations, nil
+}
 
-	rows, err := s.db.Query(query)
+// queryApplied runs a query selecting the columns of SelectApplied
+func (s *TursoStorage) queryApplied(ctx context.Context, query string, args ...any) ([]Migration, error) {
+	rows, err := s.conn().QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -90,56 +489,284 @@ func (s *TursoStorage) GetAppliedMigrations() ([]Migration, error) {
 	var migrations []Migration
 	for rows.Next() {
 		var m Migration
-		if err := rows.Scan(&m.Version, &m.Name, &m.AppliedAt); err != nil {
+		var durationMs int64
+		if err := rows.Scan(&m.Version, &m.Name, &m.AppliedAt, &m.RunID, &m.Checksum, &m.Note, &durationMs); err != nil {
 			return nil, err
 		}
+		m.Duration = time.Duration(durationMs) * time.Millisecond
 		migrations = append(migrations, m)
 	}
 
 	return migrations, rows.Err()
 }
 
+// GetAppliedVersions returns only the versions of applied migrations,
+// newest first if newestFirst. It returns no versions if the tracking table
+// doesn't exist, so it works without InitSchema.
+func (s *TursoStorage) GetAppliedVersions(newestFirst bool) ([]string, error) {
+	if missing, err := s.trackingTableMissing(); err != nil || missing {
+		return nil, err
+	}
+
+	table, err := s.readTable()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(s.dialect.SelectAppliedVersions(table, newestFirst))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []string
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, rows.Err()
+}
+
 // IsMigrationApplied checks if a migration has been applied
 func (s *TursoStorage) IsMigrationApplied(version string) (bool, error) {
-	query := `SELECT COUNT(*) FROM schema_migrations WHERE version = ?`
+	if missing, err := s.trackingTableMissing(); err != nil || missing {
+		return false, err
+	}
+
+	table, err := s.readTable()
+	if err != nil {
+		return false, err
+	}
 	var count int
-	err := s.db.QueryRow(query, version).Scan(&count)
+	err = s.db.QueryRow(s.dialect.CountApplied(table), version).Scan(&count)
 	return count > 0, err
 }
 
-// ExecuteSQL executes a SQL statement in a transaction
+// ExecuteSQL executes the statements of a SQL script in a transaction,
+// retrying transient network errors if configured
 func (s *TursoStorage) ExecuteSQL(sql string) error {
-	tx, err := s.db.Begin()
+	return s.ExecuteSQLContext(context.Background(), sql)
+}
+
+// ExecuteSQLContext is ExecuteSQL with a context; cancelling it interrupts
+// the running statement, rolling the transaction back, and stops retries
+func (s *TursoStorage) ExecuteSQLContext(ctx context.Context, sql string) error {
+	return s.withRetry(ctx, func() error {
+		return s.executeSQL(ctx, sql)
+	})
+}
+
+// executeSQL executes a SQL script statement by statement in a single
+// transaction, since some libSQL driver paths reject multiple statements
+// in one Exec
+func (s *TursoStorage) executeSQL(ctx context.Context, sql string) error {
+	tx, err := s.conn().BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	_, err = tx.Exec(sql)
+	if err := s.ExecuteSQLTxContext(ctx, tx, sql); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ExecuteSQLNoTx executes the statements of a SQL script one at a time
+// outside any transaction, for statements that can't run inside one. A
+// failure leaves the statements before it applied, so it is not retried.
+func (s *TursoStorage) ExecuteSQLNoTx(script string) error {
+	return s.ExecuteSQLNoTxContext(context.Background(), script)
+}
+
+// ExecuteSQLNoTxContext is ExecuteSQLNoTx with a context
+func (s *TursoStorage) ExecuteSQLNoTxContext(ctx context.Context, script string) error {
+	for _, stmt := range SplitStatements(script) {
+		s.log(stmt)
+		if _, err := s.conn().ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Begin starts a transaction for applying several migrations at once with
+// ExecuteSQLTx and RecordMigrationTx
+func (s *TursoStorage) Begin() (*sql.Tx, error) {
+	return s.BeginContext(context.Background())
+}
+
+// BeginContext is Begin with a context; cancelling it rolls the
+// transaction back
+func (s *TursoStorage) BeginContext(ctx context.Context) (*sql.Tx, error) {
+	return s.conn().BeginTx(ctx, nil)
+}
+
+// PinConnContext takes one connection from the pool and runs migrations,
+// their records and reads of them on it until unpin is called, so that
+// settings such as PRAGMAs that only hold for a connection apply to every
+// statement of the run. The connection runs one statement at a time.
+func (s *TursoStorage) PinConnContext(ctx context.Context) (unpin func() error, err error) {
+	conn, err := s.db.Conn(ctx)
 	if err != nil {
+		return nil, err
+	}
+	s.pinned = conn
+	return func() error {
+		s.pinned = nil
+		return conn.Close()
+	}, nil
+}
+
+// dbConn is implemented by both *sql.DB and *sql.Conn
+type dbConn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// conn returns the connection pinned by PinConnContext, or the pool
+func (s *TursoStorage) conn() dbConn {
+	if s.pinned != nil {
+		return s.pinned
+	}
+	return s.db
+}
+
+// ExecuteSQLTx executes the statements of a SQL script within the
+// transaction tx, without retrying
+func (s *TursoStorage) ExecuteSQLTx(tx *sql.Tx, script string) error {
+	return s.ExecuteSQLTxContext(context.Background(), tx, script)
+}
+
+// ExecuteSQLTxContext is ExecuteSQLTx with a context
+func (s *TursoStorage) ExecuteSQLTxContext(ctx context.Context, tx *sql.Tx, script string) error {
+	for _, stmt := range SplitStatements(script) {
+		s.log(stmt)
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExecuteStream executes SQL statements read incrementally from r in a
+// transaction, so the whole script never has to be held in memory
+func (s *TursoStorage) ExecuteStream(r io.Reader) error {
+	return s.ExecuteStreamContext(context.Background(), r)
+}
+
+// ExecuteStreamContext is ExecuteStream with a context
+func (s *TursoStorage) ExecuteStreamContext(ctx context.Context, r io.Reader) error {
+	tx, err := s.conn().BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.execStream(ctx, tx, r); err != nil {
 		return err
 	}
 
 	return tx.Commit()
 }
 
+// ExecuteStreamNoTxContext is ExecuteStreamContext without the
+// transaction, like ExecuteSQLNoTx
+func (s *TursoStorage) ExecuteStreamNoTxContext(ctx context.Context, r io.Reader) error {
+	return s.execStream(ctx, s.conn(), r)
+}
+
+// execer is implemented by *sql.DB, *sql.Conn and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// execStream executes the statements read from r with db, which is the
+// connection pool, a pinned connection or a transaction
+func (s *TursoStorage) execStream(ctx context.Context, db execer, r io.Reader) error {
+	scanner := newStatementScanner(r)
+	for scanner.Scan() {
+		s.log(scanner.Statement())
+		if _, err := db.ExecContext(ctx, scanner.Statement()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// DumpSchema returns the CREATE statements of all user-defined schema
+// objects, excluding SQLite internals and the tracking and lock tables
+func (s *TursoStorage) DumpSchema() ([]string, error) {
+	rows, err := s.db.Query(s.dialect.SelectSchema(), s.table, s.lockTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var statements []string
+	for rows.Next() {
+		var stmt string
+		if err := rows.Scan(&stmt); err != nil {
+			return nil, err
+		}
+		statements = append(statements, stmt)
+	}
+
+	return statements, rows.Err()
+}
+
 // GetCurrentVersion returns the latest applied migration version
 func (s *TursoStorage) GetCurrentVersion() (string, error) {
-	query := `
-		SELECT version 
-		FROM schema_migrations 
-		ORDER BY version DESC 
-		LIMIT 1
-	`
+	return s.GetCurrentVersionContext(context.Background())
+}
+
+// GetCurrentVersionContext is GetCurrentVersion with a context
+func (s *TursoStorage) GetCurrentVersionContext(ctx context.Context) (string, error) {
+	if missing, err := s.trackingTableMissing(); err != nil || missing {
+		return "", err
+	}
 
+	table, err := s.readTable()
+	if err != nil {
+		return "", err
+	}
 	var version string
-	err := s.db.QueryRow(query).Scan(&version)
+	err = s.conn().QueryRowContext(ctx, s.dialect.SelectCurrentVersion(table)).Scan(&version)
 	if err == sql.ErrNoRows {
 		return "", nil // No migrations applied
 	}
 	return version, err
 }
 
+// qualifiedTable returns the quoted tracking table name qualified by its schema
+func (s *TursoStorage) qualifiedTable() string {
+	return s.dialect.QuoteIdentifier(s.schema) + "." + s.dialect.QuoteIdentifier(s.table)
+}
+
+// Dialect returns the SQL dialect the storage uses
+func (s *TursoStorage) Dialect() Dialect {
+	return s.dialect
+}
+
+// SetStatementLogger sets a function called with each migration statement
+// before it is executed; nil disables logging
+func (s *TursoStorage) SetStatementLogger(fn func(stmt string)) {
+	s.logStatement = fn
+}
+
+// log passes stmt to the statement logger, if any
+func (s *TursoStorage) log(stmt string) {
+	if s.logStatement != nil {
+		s.logStatement(stmt)
+	}
+}
+
 // Close closes the database connection
 func (s *TursoStorage) Close() error {
 	return s.db.Close()
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openTemp opens a storage on a new SQLite file in a temporary directory
+func openTemp(t *testing.T, opts Options) (*TursoStorage, string) {
+	t.Helper()
+	url := "file:" + filepath.Join(t.TempDir(), "test.db")
+	s, err := NewWithOptions(url, "", opts)
+	if err != nil {
+		t.Fatalf("open %s: %v", url, err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, url
+}
+
+func TestLocalFileDatabase(t *testing.T) {
+	s, _ := openTemp(t, Options{})
+
+	if err := s.ExecuteSQL("CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT)"); err != nil {
+		t.Fatalf("ExecuteSQL: %v", err)
+	}
+	if err := s.RecordMigration("001", "create_users", "run1", "abc", "first", 5*time.Millisecond); err != nil {
+		t.Fatalf("RecordMigration: %v", err)
+	}
+
+	applied, err := s.GetAppliedMigrations()
+	if err != nil {
+		t.Fatalf("GetAppliedMigrations: %v", err)
+	}
+	if len(applied) != 1 {
+		t.Fatalf("got %d applied migrations, want 1", len(applied))
+	}
+	m := applied[0]
+	if m.Version != "001" || m.Name != "create_users" || m.RunID != "run1" || m.Checksum != "abc" || m.Note != "first" {
+		t.Errorf("got %+v", m)
+	}
+
+	version, err := s.GetCurrentVersion()
+	if err != nil || version != "001" {
+		t.Errorf("GetCurrentVersion() = %q, %v; want 001", version, err)
+	}
+}
+
+func TestMemoryDatabase(t *testing.T) {
+	s, err := New(":memory:", "")
+	if err != nil {
+		t.Fatalf("open :memory:: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.RecordMigration("001", "init", "run1", "", "", 0); err != nil {
+		t.Fatalf("RecordMigration: %v", err)
+	}
+	applied, err := s.IsMigrationApplied("001")
+	if err != nil || !applied {
+		t.Errorf("IsMigrationApplied(001) = %v, %v; want true", applied, err)
+	}
+}
+
+func TestReadOnlyLegacyTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE schema_migrations (
+			version TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+		INSERT INTO schema_migrations (version, name) VALUES ('001', 'init'), ('002', 'users');
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewWithOptions("file:"+path, "", Options{SkipInitSchema: true})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s.Close()
+
+	applied, err := s.GetAppliedMigrations()
+	if err != nil {
+		t.Fatalf("GetAppliedMigrations: %v", err)
+	}
+	if len(applied) != 2 || applied[1].Version != "002" || applied[1].RunID != "" || applied[1].Duration != 0 {
+		t.Errorf("GetAppliedMigrations() = %+v", applied)
+	}
+	if last, err := s.GetLastApplied(1); err != nil || len(last) != 1 || last[0].Version != "002" {
+		t.Errorf("GetLastApplied(1) = %+v, %v", last, err)
+	}
+	if ranged, err := s.GetAppliedMigrationsRange("002", ""); err != nil || len(ranged) != 1 {
+		t.Errorf("GetAppliedMigrationsRange(002, \"\") = %+v, %v", ranged, err)
+	}
+	if versions, err := s.GetAppliedVersions(true); err != nil || len(versions) != 2 || versions[0] != "002" {
+		t.Errorf("GetAppliedVersions(true) = %v, %v", versions, err)
+	}
+	if ok, err := s.IsMigrationApplied("001"); err != nil || !ok {
+		t.Errorf("IsMigrationApplied(001) = %v, %v", ok, err)
+	}
+	if version, err := s.GetCurrentVersion(); err != nil || version != "002" {
+		t.Errorf("GetCurrentVersion() = %q, %v", version, err)
+	}
+	if info, err := s.Info(); err != nil || !info.HasTrackingTable || info.CurrentVersion != "002" {
+		t.Errorf("Info() = %+v, %v", info, err)
+	}
+
+	// Reading must not have added the missing columns
+	if exists, err := s.hasColumn(s.table, "run_id"); err != nil || exists {
+		t.Errorf("run_id column exists = %v, %v; want the table left alone", exists, err)
+	}
+}
+
+func TestConcurrentInitSchema(t *testing.T) {
+	url := "file:" + filepath.Join(t.TempDir(), "test.db")
+
+	const n = 4
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			s, err := New(url, "")
+			if err == nil {
+				err = s.Close()
+			}
+			errs <- err
+		}()
+	}
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("concurrent New: %v", err)
+		}
+	}
+}
@@ -2,24 +2,63 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rubenmeza/turso-migrate/internal/storage"
 )
 
 // Config holds the configuration for turso-migrate and Turso database connection
 type Config struct {
-	DatabaseURL   string
-	AuthToken     string
-	MigrationsDir string
+	DatabaseURL       string
+	AuthToken         string
+	AuthTokenFile     string
+	MigrationsDir     string
+	MaxMigrationSize  int64
+	FilenamePattern   string
+	VersionFormat     string
+	Compat            string
+	Table             string
+	Namespace         string
+	TrackingDB        string
+	LockTable         string
+	LockTimeout       time.Duration
+	LockRetryInterval time.Duration
+	LockStaleAfter    time.Duration
+	Dialect           string
+	Color             string
+	MinVersion        string
+	ReadOnly          bool
+	Quiet             bool
+	Verbose           bool
+	ConfirmPhrase     string
+	ExecRetries       int
+	ExecRetryDelay    time.Duration
+	Timeout           time.Duration
+	SkipInvalid       bool
+	ExpandEnv         bool
+	// BeforeHook and AfterHook are SQL run around the migrations of an up
+	// run
+	BeforeHook string
+	AfterHook  string
 }
 
-// LoadFromEnv loads Turso configuration from environment variables
+// LoadFromEnv loads Turso configuration from environment variables only;
+// Load also reads a config file
 func LoadFromEnv() (*Config, error) {
 	cfg := &Config{
 		DatabaseURL:   os.Getenv("TURSO_DATABASE_URL"),
 		AuthToken:     os.Getenv("TURSO_AUTH_TOKEN"),
+		AuthTokenFile: os.Getenv("TURSO_AUTH_TOKEN_FILE"),
 		MigrationsDir: os.Getenv("MIGRATIONS_DIR"),
 	}
+	if err := cfg.ResolveAuthToken(); err != nil {
+		return nil, err
+	}
 
 	// Set default migrations directory
 	if cfg.MigrationsDir == "" {
@@ -37,12 +76,146 @@ func (c *Config) Validate() error {
 	if c.DatabaseURL == "" {
 		return errors.New("TURSO_DATABASE_URL is required")
 	}
-	if c.AuthToken == "" {
-		return errors.New("TURSO_AUTH_TOKEN is required")
+	if c.AuthToken == "" && !storage.IsLocalURL(c.DatabaseURL) {
+		return errors.New("TURSO_AUTH_TOKEN or TURSO_AUTH_TOKEN_FILE is required")
+	}
+	return nil
+}
+
+// ResolveAuthToken sets AuthToken to the contents of AuthTokenFile, with
+// surrounding whitespace removed, and clears AuthTokenFile so resolving
+// again is harmless. It is an error to give both, or a file that is empty.
+func (c *Config) ResolveAuthToken() error {
+	if c.AuthTokenFile == "" {
+		return nil
+	}
+	if c.AuthToken != "" {
+		return errors.New("an auth token and an auth token file cannot both be given")
+	}
+
+	content, err := os.ReadFile(c.AuthTokenFile)
+	if err != nil {
+		return fmt.Errorf("failed to read auth token file: %w", err)
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return fmt.Errorf("auth token file %s is empty", c.AuthTokenFile)
 	}
+	c.AuthToken = token
+	c.AuthTokenFile = ""
 	return nil
 }
 
+// CheckMinVersion returns an error asking to upgrade if running is older
+// than MinVersion. No check is made when MinVersion is empty.
+func (c *Config) CheckMinVersion(running string) error {
+	if c.MinVersion == "" {
+		return nil
+	}
+
+	cmp, err := CompareVersions(running, c.MinVersion)
+	if err != nil {
+		return fmt.Errorf("invalid min_version: %w", err)
+	}
+	if cmp < 0 {
+		return fmt.Errorf("this project requires turso-migrate %s or newer, but %s is running; please upgrade", c.MinVersion, running)
+	}
+	return nil
+}
+
+// CompareVersions compares two semantic versions such as "1.4.0" or
+// "v1.4.0-rc.1", returning -1, 0 or 1. A pre-release sorts before the
+// release it precedes; build metadata is ignored.
+func CompareVersions(a, b string) (int, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range va.parts {
+		if va.parts[i] != vb.parts[i] {
+			if va.parts[i] < vb.parts[i] {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+
+	switch {
+	case va.pre == vb.pre:
+		return 0, nil
+	case va.pre == "":
+		return 1, nil
+	case vb.pre == "":
+		return -1, nil
+	case va.pre < vb.pre:
+		return -1, nil
+	default:
+		return 1, nil
+	}
+}
+
+// version is a parsed semantic version
+type version struct {
+	parts [3]int
+	pre   string
+}
+
+// parseVersion parses a semantic version, treating missing minor and patch
+// numbers as zero
+func parseVersion(s string) (version, error) {
+	var v version
+
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	s, _, _ = strings.Cut(s, "+")
+	s, v.pre, _ = strings.Cut(s, "-")
+
+	fields := strings.Split(s, ".")
+	if len(fields) > 3 {
+		return v, fmt.Errorf("invalid version: %q", s)
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version: %q", s)
+		}
+		v.parts[i] = n
+	}
+	return v, nil
+}
+
+// ParseSize parses a byte size such as "512KB", "16MB" or "1048576"
+func ParseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	} {
+		if strings.HasSuffix(s, unit.suffix) {
+			multiplier = unit.size
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+	return n * multiplier, nil
+}
+
 // EnsureMigrationsDir creates the migrations directory if it doesn't exist
 func (c *Config) EnsureMigrationsDir() error {
 	return os.MkdirAll(c.MigrationsDir, 0755)
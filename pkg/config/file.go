@@ -0,0 +1,349 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultFile is the config file looked for in the working directory when
+// no path is given
+const DefaultFile = ".turso-migrate.yaml"
+
+// File is a parsed config file. Settings are keyed by the global flag name
+// with dashes replaced by underscores, such as database_url; see Set for
+// the keys accepted.
+type File struct {
+	Path     string
+	Settings map[string]string
+	// Profiles holds the named sections under "profiles", each with
+	// settings that override the top-level ones when selected
+	Profiles map[string]map[string]string
+}
+
+// OpenFile reads the config file at path. With an empty path it reads
+// DefaultFile from the working directory if it exists, returning nil
+// without error if it doesn't.
+func OpenFile(path string) (*File, error) {
+	explicit := path != ""
+	if !explicit {
+		path = DefaultFile
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	tree, err := parseYAML(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	file := &File{Path: path, Profiles: make(map[string]map[string]string)}
+	if profiles, ok := tree["profiles"]; ok {
+		delete(tree, "profiles")
+		sections, ok := profiles.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: profiles must be a mapping of profile names to settings", path)
+		}
+		for name, section := range sections {
+			m, ok := section.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s: profile %q must be a mapping of settings", path, name)
+			}
+			if file.Profiles[name], err = flatSettings(m); err != nil {
+				return nil, fmt.Errorf("%s: profile %q: %w", path, name, err)
+			}
+		}
+	}
+	if file.Settings, err = flatSettings(tree); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return file, nil
+}
+
+// flatSettings converts a parsed mapping to settings, rejecting nested
+// mappings
+func flatSettings(m map[string]any) (map[string]string, error) {
+	settings := make(map[string]string, len(m))
+	for key, value := range m {
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("setting %q must be a single value", key)
+		}
+		settings[key] = s
+	}
+	return settings, nil
+}
+
+// UseProfile merges the settings of the named profile over the top-level
+// ones
+func (f *File) UseProfile(name string) error {
+	profile, ok := f.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(f.Profiles))
+		for n := range f.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return fmt.Errorf("%s: no profile %q: the file defines no profiles", f.Path, name)
+		}
+		return fmt.Errorf("%s: no profile %q, available: %s", f.Path, name, strings.Join(names, ", "))
+	}
+	for key, value := range profile {
+		f.Settings[key] = value
+	}
+	return nil
+}
+
+// Keys returns the keys of the file's settings in sorted order
+func (f *File) Keys() []string {
+	keys := make([]string, 0, len(f.Settings))
+	for key := range f.Settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Apply sets every setting of the file on c
+func (f *File) Apply(c *Config) error {
+	for _, key := range f.Keys() {
+		if err := c.Set(key, f.Settings[key]); err != nil {
+			return fmt.Errorf("%s: %w", f.Path, err)
+		}
+	}
+	return nil
+}
+
+// Load builds a Config from the config file at path, or DefaultFile if
+// path is empty and it exists, overridden by the TURSO_DATABASE_URL,
+// TURSO_AUTH_TOKEN, TURSO_AUTH_TOKEN_FILE and MIGRATIONS_DIR environment
+// variables
+func Load(path string) (*Config, error) {
+	return LoadProfile(path, "")
+}
+
+// LoadProfile is Load with the settings of the named profile of the config
+// file overriding its top-level ones. A profile requires a config file.
+func LoadProfile(path, profile string) (*Config, error) {
+	cfg := &Config{}
+
+	file, err := OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if file == nil && profile != "" {
+		return nil, fmt.Errorf("profile %q selected but no config file found", profile)
+	}
+	if file != nil {
+		if profile != "" {
+			if err := file.UseProfile(profile); err != nil {
+				return nil, err
+			}
+		}
+		if err := file.Apply(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	for env, field := range map[string]*string{
+		"TURSO_DATABASE_URL":    &cfg.DatabaseURL,
+		"TURSO_AUTH_TOKEN":      &cfg.AuthToken,
+		"TURSO_AUTH_TOKEN_FILE": &cfg.AuthTokenFile,
+		"MIGRATIONS_DIR":        &cfg.MigrationsDir,
+	} {
+		if value := os.Getenv(env); value != "" {
+			*field = value
+		}
+	}
+	if err := cfg.ResolveAuthToken(); err != nil {
+		return nil, err
+	}
+
+	if cfg.MigrationsDir == "" {
+		cfg.MigrationsDir = "./migrations"
+	}
+	cfg.MigrationsDir = filepath.Clean(cfg.MigrationsDir)
+
+	return cfg, cfg.Validate()
+}
+
+// Set sets the field named by a config file key, parsing value as the
+// field's type
+func (c *Config) Set(key, value string) error {
+	var err error
+	switch key {
+	case "database_url":
+		c.DatabaseURL = value
+	case "auth_token":
+		c.AuthToken = value
+	case "auth_token_file":
+		c.AuthTokenFile = value
+	case "migrations_dir":
+		c.MigrationsDir = value
+	case "table":
+		c.Table = value
+	case "max_migration_size":
+		c.MaxMigrationSize, err = ParseSize(value)
+	case "filename_pattern":
+		c.FilenamePattern = value
+	case "version_format":
+		c.VersionFormat = value
+	case "compat":
+		c.Compat = value
+	case "namespace":
+		c.Namespace = value
+	case "tracking_db":
+		c.TrackingDB = value
+	case "lock_table":
+		c.LockTable = value
+	case "lock_timeout":
+		c.LockTimeout, err = time.ParseDuration(value)
+	case "lock_retry_interval":
+		c.LockRetryInterval, err = time.ParseDuration(value)
+	case "lock_stale_after":
+		c.LockStaleAfter, err = time.ParseDuration(value)
+	case "dialect":
+		c.Dialect = value
+	case "color":
+		c.Color = value
+	case "min_version":
+		c.MinVersion = value
+	case "read_only":
+		c.ReadOnly, err = strconv.ParseBool(value)
+	case "quiet":
+		c.Quiet, err = strconv.ParseBool(value)
+	case "verbose":
+		c.Verbose, err = strconv.ParseBool(value)
+	case "confirm_phrase":
+		c.ConfirmPhrase = value
+	case "exec_retries":
+		c.ExecRetries, err = strconv.Atoi(value)
+	case "exec_retry_delay":
+		c.ExecRetryDelay, err = time.ParseDuration(value)
+	case "timeout":
+		c.Timeout, err = time.ParseDuration(value)
+	case "skip_invalid":
+		c.SkipInvalid, err = strconv.ParseBool(value)
+	case "expand_env":
+		c.ExpandEnv, err = strconv.ParseBool(value)
+	case "before_hook":
+		c.BeforeHook = value
+	case "after_hook":
+		c.AfterHook = value
+	default:
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", key, value, err)
+	}
+	return nil
+}
+
+// parseYAML parses the subset of YAML used by config files: "key: value"
+// lines, nested by indentation with spaces, with optional quotes around
+// values and "#" comments. Values are strings and nested mappings are
+// map[string]any.
+func parseYAML(content string) (map[string]any, error) {
+	type level struct {
+		indent int
+		m      map[string]any
+	}
+	root := make(map[string]any)
+	stack := []level{{indent: -1, m: root}}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for n := 1; scanner.Scan(); n++ {
+		line := stripComment(scanner.Text())
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		trimmed := strings.TrimLeft(line, " ")
+		indent := len(line) - len(trimmed)
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: indent with spaces, not tabs", n)
+		}
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			return nil, fmt.Errorf("line %d: lists are not supported", n)
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", n)
+		}
+
+		for stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		parent := stack[len(stack)-1].m
+		if _, dup := parent[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", n, key)
+		}
+
+		value = strings.TrimSpace(value)
+		if value == "" {
+			child := make(map[string]any)
+			parent[key] = child
+			stack = append(stack, level{indent: indent, m: child})
+			continue
+		}
+
+		unquoted, err := unquote(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		parent[key] = unquoted
+	}
+	return root, scanner.Err()
+}
+
+// stripComment removes a "#" comment from line, ignoring "#" inside quotes
+// or not preceded by a space, as in URLs
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquote removes double or single quotes around a value
+func unquote(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		s, err := strconv.Unquote(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid quoted value %s", value)
+		}
+		return s, nil
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return "", fmt.Errorf("invalid quoted value %s", value)
+		}
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+	}
+	return value, nil
+}
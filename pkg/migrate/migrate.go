@@ -0,0 +1,208 @@
+// Package migrate runs turso-migrate migrations from Go programs, for
+// example at application startup, returning structured results instead of
+// printing them.
+//
+// It covers applying pending migrations and reading their state. Rolling
+// back and the other commands are only available from the CLI, which runs
+// the same engine, configured the same way, without going through
+// Migrator.
+//
+//	m, err := migrate.New(&config.Config{
+//		DatabaseURL:   os.Getenv("TURSO_DATABASE_URL"),
+//		AuthToken:     os.Getenv("TURSO_AUTH_TOKEN"),
+//		MigrationsDir: "./migrations",
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	defer m.Close()
+//
+//	result, err := m.Up(ctx)
+package migrate
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"time"
+
+	"github.com/rubenmeza/turso-migrate/internal/migration"
+	"github.com/rubenmeza/turso-migrate/internal/storage"
+	"github.com/rubenmeza/turso-migrate/pkg/config"
+)
+
+// Error is the error returned when a migration operation fails. Its Code is
+// one of the Code constants and Version is the migration it concerns, if
+// any; match it with errors.As or compare codes with errors.Is against the
+// Err sentinels.
+type Error = migration.Error
+
+// Error codes, see the README for their meaning
+const (
+	CodeMigrationFailed  = migration.CodeMigrationFailed
+	CodeRecordFailed     = migration.CodeRecordFailed
+	CodeFileNotFound     = migration.CodeFileNotFound
+	CodeInvalidMigration = migration.CodeInvalidMigration
+	CodeStorageFailed    = migration.CodeStorageFailed
+	CodeApprovalRequired = migration.CodeApprovalRequired
+	CodeAuthFailed       = migration.CodeAuthFailed
+	CodeLocked           = migration.CodeLocked
+	CodeReadOnly         = migration.CodeReadOnly
+	CodeChecksumMismatch = migration.CodeChecksumMismatch
+	CodeOutOfOrder       = migration.CodeOutOfOrder
+	CodeCancelled        = migration.CodeCancelled
+)
+
+// Sentinel errors, matched with errors.Is by code
+var (
+	ErrMigrationFailed  = migration.ErrMigrationFailed
+	ErrRecordFailed     = migration.ErrRecordFailed
+	ErrFileNotFound     = migration.ErrFileNotFound
+	ErrInvalidMigration = migration.ErrInvalidMigration
+	ErrStorageFailed    = migration.ErrStorageFailed
+	ErrApprovalRequired = migration.ErrApprovalRequired
+	ErrAuthFailed       = migration.ErrAuthFailed
+	ErrLocked           = migration.ErrLocked
+	ErrReadOnly         = migration.ErrReadOnly
+	ErrChecksumMismatch = migration.ErrChecksumMismatch
+	ErrOutOfOrder       = migration.ErrOutOfOrder
+	ErrCancelled        = migration.ErrCancelled
+)
+
+// Migration is a migration applied by Up
+type Migration struct {
+	Version  string
+	Name     string
+	Duration time.Duration
+}
+
+// Result describes an Up run
+type Result struct {
+	// Applied lists the migrations applied, in the order they finished
+	Applied []Migration
+	// Duration is how long the whole run took
+	Duration time.Duration
+}
+
+// Status is the status of one migration file
+type Status struct {
+	Version string
+	Name    string
+	Applied bool
+	// AppliedAt is zero for pending migrations
+	AppliedAt time.Time
+	// OutOfOrder marks a pending migration older than the newest applied one
+	OutOfOrder bool
+	// Note is the migration's note, see create --note
+	Note string
+}
+
+// Migrator applies migrations to one database
+type Migrator struct {
+	store  *storage.TursoStorage
+	engine *migration.Engine
+	// authToken is kept only to mask it in the errors returned
+	authToken string
+}
+
+// New connects to the database described by cfg and reads migrations from
+// cfg.MigrationsDir. Call Close when done.
+func New(cfg *config.Config) (*Migrator, error) {
+	dir := cfg.MigrationsDir
+	if dir == "" {
+		dir = "./migrations"
+	}
+	return open(cfg, func(store *storage.TursoStorage) *migration.Engine {
+		return migration.NewEngine(store, dir)
+	})
+}
+
+// NewFS is like New but reads migrations from the root of fsys, such as an
+// embed.FS narrowed with fs.Sub, so they can be compiled into the binary.
+// cfg.MigrationsDir is ignored.
+func NewFS(cfg *config.Config, fsys fs.FS) (*Migrator, error) {
+	return open(cfg, func(store *storage.TursoStorage) *migration.Engine {
+		return migration.NewEngineFS(store, fsys, ".")
+	})
+}
+
+// open connects to the database and configures the engine built by newEngine
+func open(cfg *config.Config, newEngine func(*storage.TursoStorage) *migration.Engine) (*Migrator, error) {
+	c := *cfg
+	if err := c.ResolveAuthToken(); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	if c.Table == "" {
+		c.Table = migration.CompatTable(c.Compat)
+	}
+
+	store, err := migration.OpenStorage(&c, false)
+	if err != nil {
+		return nil, storage.RedactError(err, c.AuthToken)
+	}
+
+	engine := newEngine(store)
+	engine.SetOutput(io.Discard)
+	engine.SetWarningOutput(io.Discard)
+	if err := engine.Configure(&c); err != nil {
+		store.Close()
+		return nil, err
+	}
+	return &Migrator{store: store, engine: engine, authToken: c.AuthToken}, nil
+}
+
+// SetOutput sets where the progress messages and warnings the CLI prints
+// are written; they are discarded by default
+func (m *Migrator) SetOutput(w io.Writer) {
+	m.engine.SetOutput(w)
+	m.engine.SetWarningOutput(w)
+}
+
+// Up applies all pending migrations in order, each in its own transaction,
+// stopping at the first failure. The Result lists the migrations applied
+// before any error. Cancelling ctx interrupts the running migration, which
+// is rolled back, and fails with a CodeCancelled error.
+func (m *Migrator) Up(ctx context.Context) (*Result, error) {
+	start := time.Now()
+	applied, err := m.engine.UpApplied(ctx, migration.UpOptions{})
+
+	result := &Result{Applied: []Migration{}}
+	for _, a := range applied {
+		result.Applied = append(result.Applied, Migration{Version: a.Version, Name: a.Name, Duration: a.Duration})
+	}
+	result.Duration = time.Since(start)
+	return result, storage.RedactError(err, m.authToken)
+}
+
+// Status returns the status of every migration file in version order
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	statuses, err := m.engine.Statuses(ctx)
+	if err != nil {
+		return nil, storage.RedactError(err, m.authToken)
+	}
+
+	result := make([]Status, 0, len(statuses))
+	for _, s := range statuses {
+		status := Status{Version: s.Version, Name: s.Name, Applied: s.Applied, OutOfOrder: s.OutOfOrder, Note: s.Note}
+		if s.AppliedAt != nil {
+			status.AppliedAt = *s.AppliedAt
+		}
+		result = append(result, status)
+	}
+	return result, nil
+}
+
+// Version returns the version of the last applied migration, or "" if none
+// has been applied
+func (m *Migrator) Version(ctx context.Context) (string, error) {
+	version, err := m.store.GetCurrentVersionContext(ctx)
+	return version, storage.RedactError(err, m.authToken)
+}
+
+// Close closes the database connection
+func (m *Migrator) Close() error {
+	return m.store.Close()
+}
@@ -0,0 +1,60 @@
+package migrate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rubenmeza/turso-migrate/pkg/config"
+)
+
+func TestNewWithAuthTokenFileFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	migrations := filepath.Join(dir, "migrations")
+	if err := os.Mkdir(migrations, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TURSO_DATABASE_URL", "file:"+filepath.Join(dir, "test.db"))
+	t.Setenv("TURSO_AUTH_TOKEN", "")
+	t.Setenv("TURSO_AUTH_TOKEN_FILE", tokenFile)
+	t.Setenv("MIGRATIONS_DIR", migrations)
+
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv: %v", err)
+	}
+	if cfg.AuthToken != "secret" {
+		t.Errorf("AuthToken = %q, want the contents of the token file", cfg.AuthToken)
+	}
+
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New after LoadFromEnv: %v", err)
+	}
+	defer m.Close()
+
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Errorf("Up: %v", err)
+	}
+}
+
+func TestNewMasksAuthToken(t *testing.T) {
+	const token = "secret-token-value"
+	_, err := New(&config.Config{
+		DatabaseURL:   "http://127.0.0.1:1",
+		AuthToken:     token,
+		MigrationsDir: t.TempDir(),
+	})
+	if err == nil {
+		t.Fatal("New connected to a closed port")
+	}
+	if strings.Contains(err.Error(), token) {
+		t.Errorf("error shows the auth token: %v", err)
+	}
+}